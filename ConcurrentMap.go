@@ -1,141 +1,340 @@
 package HighPerformanceMap
 
 import (
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 type concurrentMap struct {
-	partitions  []map[uint64]int // 对每个桶中的数据添加map
-	lenOfBucket int              // 分桶，目的加快map查找
-	free        []int            // 用户记录删除切片的位置
-	innerSlice  []*innerSlice    // 用户记录所用的值的位置
-	mu          sync.RWMutex
+	partitions  []*partition // 对每个桶中的数据添加map
+	lenOfBucket int          // 分桶，目的加快map查找
+	mu          sync.RWMutex // 读写 key 时加读锁，切换分区表时加写锁
+	count       int64        // key 的数量，原子操作
+
+	oldPartitions []*partition     // 扩缩容时正在迁移的旧分区表
+	rehashIdx     int              // 下一个要迁移的旧分区，由 rehashMu 的写锁保护
+	rehashMu      sync.RWMutex     // 迁移时加写锁，遍历和事务提交加读锁，期间暂停迁移
+	backend       PartitionBackend // 分区内使用的 hash 索引
+	minBucket     int              // 自动缩容的下限
+	minLoad       int              // 平均每个分区的 key 少于 minLoad 时缩容，0 表示关闭
+	maxLoad       int              // 平均每个分区的 key 多于 maxLoad 时扩容，0 表示关闭
+	adaptive      *adaptiveState   // EnableAdaptive 之后才会创建
+	weigher       Weigher          // SetMaxBytes 之后才会设置
+	maxBytes      int64            // 所有值的权重之和的上限，原子操作
+	bytes         int64            // 所有值的权重之和，原子操作
+	epoch         uint32           // SetMaxBytes 之后每次写入加一，访问时记录到 innerSlice.epoch，原子操作
+	table         atomic.Value     // *partitionTable，无锁读时使用
+	lockFreeReads int32            // 1 表示 Get 读取分区的只读副本，原子操作
+
+	jsonKeyKind   JSONKeyKind  // 反序列化 JSON 时 key 的类型
+	jsonValueType reflect.Type // 反序列化 JSON 时 value 的类型，nil 表示 any
+
+	watchers watchHub         // Watch / Subscribe 的订阅者
+	indexes  []secondaryIndex // 有序、前缀等附加索引，默认为空
+
+	prefixIndex *radixTree // EnablePrefixIndex 之后才会创建
+	disk        *diskTier  // EnableDiskTier 之后才会创建
+
+	clock func() time.Time // 判断过期使用的时钟，nil 表示 time.Now
 }
 
 type innerSlice struct {
 	key   any
 	Value unsafe.Pointer
+	next  int32  // hash 相同的下一个位置，-1 表示没有
+	epoch uint32 // 最近一次访问时 m.epoch 的值，只在 SetMaxBytes 之后更新，原子操作
 }
 
+// secondaryIndex 附加在 map 上的 key 索引，由索引自己加锁，
+// 调用时持有 key 所在分区的写锁
+type secondaryIndex interface {
+	set(data *innerSlice)
+	delete(key any)
+}
+
+// Partitionable is implemented by map keys. PartitionKey is the 64-bit hash
+// of the key; keys with the same hash are told apart by comparing Value, so
+// Value must return a comparable value.
 type Partitionable interface {
 	Value() any
 	PartitionKey() uint64
 }
 
-func CreateConcurrentSliceMap(lenOfBucket int) *concurrentMap {
-	partitions := make([]map[uint64]int, lenOfBucket)
-	for i := 0; i < lenOfBucket; i++ {
-		partitions[i] = make(map[uint64]int)
+// Map is the API shared by the map implementations in this package, so the
+// implementation can be chosen when the map is created.
+type Map interface {
+	Get(key Partitionable) (any, bool)
+	GetString(key string) (any, bool)
+	GetInt64(key int64) (any, bool)
+	Set(key Partitionable, v any)
+	Delete(key Partitionable)
+	Len() int
+	Range(f func(key, value any) bool)
+}
+
+var (
+	_ Map = (*concurrentMap)(nil)
+	_ Map = (*orderedMap)(nil)
+	_ Map = (*readMostlyMap)(nil)
+	_ Map = (*cuckooMap)(nil)
+)
+
+// keyEqualer 内置 key 实现该接口，比较时不需要调用 Value 产生分配
+type keyEqualer interface {
+	equal(stored any) bool
+}
+
+func keyEqual(key Partitionable, stored any) bool {
+	if e, ok := key.(keyEqualer); ok {
+		return e.equal(stored)
 	}
-	return &concurrentMap{
-		partitions:  partitions,
-		lenOfBucket: lenOfBucket,
-		free:        make([]int, 0, 1024),
-		innerSlice:  make([]*innerSlice, 0, 1024),
+	return key.Value() == stored
+}
+
+func CreateConcurrentSliceMap(lenOfBucket int) *concurrentMap {
+	return CreateConcurrentSliceMapWithBackend(lenOfBucket, BuiltinMapBackend)
+}
+
+// CreateConcurrentSliceMapWithBackend is CreateConcurrentSliceMap with the
+// hash index used inside each partition chosen by backend. CuckooBackend
+// bounds the cost of a lookup; with EnableLockFreeReads it also takes no
+// lock.
+func CreateConcurrentSliceMapWithBackend(lenOfBucket int, backend PartitionBackend) *concurrentMap {
+	m := &concurrentMap{backend: backend, minBucket: lenOfBucket}
+	m.storeTable(newPartitions(lenOfBucket, backend), nil)
+	return m
+}
+
+// lockPartition 找到 keyIndex 所在的分区并加锁，调用方需持有 m.mu 的读锁
+func (m *concurrentMap) lockPartition(keyIndex uint64, write bool) *partition {
+	if old := m.oldPartitions; old != nil {
+		p := old[keyIndex%uint64(len(old))]
+		m.lock(p, write)
+		if !p.migrated {
+			return p
+		}
+		p.unlock(write)
 	}
+
+	p := m.partitions[keyIndex%uint64(m.lenOfBucket)]
+	m.lock(p, write)
+	return p
 }
 
-func (m *concurrentMap) getPartition(key Partitionable) map[uint64]int {
-	partitionID := key.PartitionKey() % uint64(m.lenOfBucket)
-	return m.partitions[partitionID]
+func (m *concurrentMap) lock(p *partition, write bool) {
+	if m.adaptive != nil {
+		p.lockMeasured(write)
+	} else {
+		p.lock(write)
+	}
 }
 
 func (m *concurrentMap) getValue(v unsafe.Pointer) any {
-	return *(*any)(v)
+	value := *(*any)(v)
+	if t, ok := value.(*ttlValue); ok {
+		return t.value
+	}
+	return value
 }
 
 func (m *concurrentMap) Len() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	length := 0
-	for _, data := range m.partitions {
-		length += len(data)
+	n := int(atomic.LoadInt64(&m.count))
+	if m.disk != nil {
+		n += m.disk.len()
 	}
-
-	return length
+	return n
 }
 
-func (m *concurrentMap) Range(f func(key, value any) bool) {
+// rangeData 遍历所有值，f 执行时持有所在分区的读锁，遍历期间暂停迁移
+func (m *concurrentMap) rangeData(f func(data *innerSlice) bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	m.rehashMu.RLock()
+	defer m.rehashMu.RUnlock()
 
-	for _, mapData := range m.partitions {
-		for _, index := range mapData {
-			data := m.innerSlice[index]
-			if !f(data.key, m.getValue(data.Value)) {
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.mu.RLock()
+			ok := p.migrated || p.rangeData(f)
+			p.mu.RUnlock()
+			if !ok {
 				return
 			}
 		}
 	}
 }
 
+func (m *concurrentMap) Range(f func(key, value any) bool) {
+	ok := true
+	m.rangeData(func(data *innerSlice) bool {
+		ok = m.expired(data) || f(data.key, m.getValue(data.Value))
+		return ok
+	})
+	if ok && m.disk != nil {
+		m.disk.rangeEntries(m.now(), f)
+	}
+}
+
+// FreeLen returns the number of slots freed by deletes and not reused yet.
+// Free slots belong to their partition and are only reused by keys of the
+// same partition.
 func (m *concurrentMap) FreeLen() int {
+	length := 0
+	m.rangePartitions(func(p *partition) {
+		length += len(p.free)
+	})
+	return length
+}
+
+// rangePartitions 依次对每个未迁移的分区加读锁并调用 f
+func (m *concurrentMap) rangePartitions(f func(p *partition)) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return len(m.free)
+
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.mu.RLock()
+			if !p.migrated {
+				f(p)
+			}
+			p.mu.RUnlock()
+		}
+	}
 }
 
 func (m *concurrentMap) Get(key Partitionable) (any, bool) {
-	im := m.getPartition(key)
-	keyIndex := key.PartitionKey()
+	return m.getFunc(key.PartitionKey(), func(stored any) bool {
+		return keyEqual(key, stored)
+	})
+}
 
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *concurrentMap) getFunc(keyIndex uint64, equal func(stored any) bool) (any, bool) {
+	if m.lockFree() {
+		return m.getLockFree(keyIndex, equal)
+	}
 
-	if index, ok := im[keyIndex]; ok {
-		data := m.innerSlice[index]
-		return m.getValue(data.Value), true
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, false)
+	var data *innerSlice
+	if index, _, ok := p.lookupFunc(keyIndex, equal); ok {
+		data = p.innerSlice[index]
 	}
+	p.mu.RUnlock()
+	m.mu.RUnlock()
 
-	return nil, false
+	m.touch(data)
+	if v, ok := m.liveValue(keyIndex, data); ok || m.disk == nil {
+		return v, ok
+	}
+	return m.promote(keyIndex, equal)
 }
 
 func (m *concurrentMap) Set(key Partitionable, v any) {
-	im := m.getPartition(key)
+	m.set(key, v, v)
+}
+
+// set 写入 key，stored 为 v 本身或者带过期时间的 *ttlValue
+func (m *concurrentMap) set(key Partitionable, v, stored any) {
 	keyIndex := key.PartitionKey()
+	data := &innerSlice{
+		key:   key.Value(),
+		Value: unsafe.Pointer(&stored),
+	}
 
 	m.mu.RLock()
-	index, ok := im[keyIndex]
+	p := m.lockPartition(keyIndex, true)
+	old := p.set(keyIndex, key, data)
+	m.setLocked(p, keyIndex, data, old)
+	if old == nil && m.disk != nil {
+		// 同一个 key 只在内存或磁盘中的一处
+		m.disk.remove(keyIndex, setKey{keyIndex, data.key}.equal)
+	}
+	if m.watchers.active() {
+		ev := Event{Type: EventSet, Key: data.key, NewValue: v}
+		if old != nil {
+			ev.OldValue = m.getValue(old)
+		}
+		m.watchers.enqueue(keyIndex, ev)
+	}
+	p.mu.Unlock()
+	if old == nil {
+		atomic.AddInt64(&m.count, 1)
+	}
 	m.mu.RUnlock()
+	m.watchers.deliver()
+	m.rehash()
+	m.evict()
+}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	if ok {
-		m.innerSlice[index] = &innerSlice{
-			key.Value(),
-			unsafe.Pointer(&v),
+func (m *concurrentMap) Delete(key Partitionable) {
+	m.delete(key)
+}
+
+// setLocked 写入 data 后更新附加索引、权重和只读副本，old 为被覆盖的值，调用方持有分区的写锁
+func (m *concurrentMap) setLocked(p *partition, keyIndex uint64, data *innerSlice, old unsafe.Pointer) {
+	for _, idx := range m.indexes {
+		idx.set(data)
+	}
+	if m.weigher != nil {
+		atomic.StoreUint32(&data.epoch, atomic.AddUint32(&m.epoch, 1))
+		w := m.weigher(data.key, m.getValue(data.Value))
+		if old != nil {
+			w -= m.weigher(data.key, m.getValue(old))
 		}
-		return
+		m.addWeight(p, w)
 	}
+	if m.lockFree() {
+		p.updateView(keyIndex, data.key, data)
+	}
+}
 
-	n := len(m.innerSlice)
-	if len(m.free) > 0 {
-		n = m.free[0]
-		m.free = m.free[1:]
+// removeLocked 从分区中删除 index 位置的值并更新附加索引和计数，调用方持有分区的写锁
+func (m *concurrentMap) removeLocked(p *partition, keyIndex uint64, index, prev int) *innerSlice {
+	data := m.unlinkLocked(p, keyIndex, index, prev)
+	for _, idx := range m.indexes {
+		idx.delete(data.key)
 	}
+	return data
+}
 
-	m.innerSlice = append(m.innerSlice, &innerSlice{
-		key.Value(),
-		unsafe.Pointer(&v),
-	})
-	im[keyIndex] = n
+// unlinkLocked 与 removeLocked 相同，但不更新附加索引，调用方持有分区的写锁
+func (m *concurrentMap) unlinkLocked(p *partition, keyIndex uint64, index, prev int) *innerSlice {
+	data := p.unlink(keyIndex, index, prev)
+	atomic.AddInt64(&m.count, -1)
+	if m.weigher != nil {
+		m.addWeight(p, -m.weigher(data.key, m.getValue(data.Value)))
+	}
+	if m.lockFree() {
+		p.updateView(keyIndex, data.key, nil)
+	}
+	return data
 }
 
-func (m *concurrentMap) Delete(key Partitionable) {
-	im := m.getPartition(key)
+// delete 删除 key，返回 key 是否存在
+func (m *concurrentMap) delete(key Partitionable) bool {
 	keyIndex := key.PartitionKey()
 
 	m.mu.RLock()
-	index, ok := im[keyIndex]
-	m.mu.RUnlock()
-
+	p := m.lockPartition(keyIndex, true)
+	index, prev, ok := p.lookup(keyIndex, key)
+	var data *innerSlice
 	if ok {
-		m.mu.Lock()
-		m.free = append(m.free, index)
-		m.innerSlice[index] = nil
-		delete(im, keyIndex)
-		m.mu.Unlock()
+		data = m.removeLocked(p, keyIndex, index, prev)
+	} else if m.disk != nil {
+		data = m.takeSpilled(keyIndex, func(stored any) bool {
+			return keyEqual(key, stored)
+		})
+		ok = data != nil
 	}
+	if ok && m.watchers.active() {
+		m.watchers.enqueue(keyIndex, Event{Type: EventDelete, Key: data.key, OldValue: m.getValue(data.Value)})
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.watchers.deliver()
+	m.rehash()
+	return ok
 }
@@ -296,6 +296,51 @@ func BenchmarkSyncAndMapAndPMapReadC(b *testing.B) {
 		}
 	})
 }
+func BenchmarkSyncAndMapAndPMapReadD(b *testing.B) {
+	num := 100
+	mapData := CreateConcurrentSliceMap(99)
+	for i := 0; i < num; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	mapData.EnableLockFreeReads()
+
+	i := int64(0)
+	id := 0
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id = int(atomic.AddInt64(&i, 1))
+		for pb.Next() {
+			if _, ok := mapData.Get(StrKey(strconv.Itoa(id))); ok {
+
+			} else {
+				b.Errorf("error %v", id)
+			}
+		}
+	})
+}
+func BenchmarkSyncAndMapAndPMapReadE(b *testing.B) {
+	num := 100
+	mapData := CreateReadMostlyMap()
+	for i := 0; i < num; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+
+	i := int64(0)
+	id := 0
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id = int(atomic.AddInt64(&i, 1))
+		for pb.Next() {
+			if _, ok := mapData.Get(StrKey(strconv.Itoa(id))); ok {
+
+			} else {
+				b.Errorf("error %v", id)
+			}
+		}
+	})
+}
 
 // performance Test delete
 func BenchmarkSyncAndMapAndPMapDeleteA(b *testing.B) {
@@ -404,7 +449,7 @@ func TestSyncAndMapAndPMapGCB(t *testing.T) {
 	debug.ReadGCStats(&stats)
 	t.Logf("numGC --> %v, PauseTotal --> %v", stats.NumGC, stats.PauseTotal)
 
-	runtime.KeepAlive(mapData)
+	runtime.KeepAlive(&mapData)
 }
 
 func TestSyncAndMapAndPMapGCC(t *testing.T) {
@@ -625,7 +670,7 @@ func TestSyncAndMapAndPMapBigGCB(t *testing.T) {
 	debug.ReadGCStats(&stats)
 	t.Logf("numGC --> %v, PauseTotal --> %v", stats.NumGC, stats.PauseTotal)
 
-	runtime.KeepAlive(mapData)
+	runtime.KeepAlive(&mapData)
 }
 
 func TestSyncAndMapAndPMapBigGCC(t *testing.T) {
@@ -652,3 +697,28 @@ func TestSyncAndMapAndPMapBigGCC(t *testing.T) {
 	t.Logf("numGC --> %v, PauseTotal --> %v", stats.NumGC, stats.PauseTotal)
 	runtime.KeepAlive(mapData)
 }
+
+func TestSetAfterDelete(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.Set(StrKey("Hello"), 1)
+	mapData.Set(StrKey("World"), 2)
+	mapData.Delete(StrKey("Hello"))
+	mapData.Set(StrKey("Jinjin"), 3)
+
+	if v, ok := mapData.Get(StrKey("Jinjin")); !ok || v.(int) != 3 {
+		t.Error("set after delete failed")
+	}
+	// 空闲位置属于各自的分区，Jinjin 与 Hello 不在同一个分区时不会复用
+	sameBucket := StrKey("Hello").PartitionKey()%99 == StrKey("Jinjin").PartitionKey()%99
+	if free := mapData.FreeLen(); mapData.Len() != 2 || sameBucket && free != 0 || !sameBucket && free != 1 {
+		t.Errorf("len --> %v, free len --> %v", mapData.Len(), free)
+	}
+
+	// 同一个分区中的空闲位置被复用
+	mapData.Set(StrKey("Hello"), 4)
+	mapData.Delete(StrKey("Jinjin"))
+	mapData.Set(StrKey("Jinjin"), 5)
+	if mapData.Len() != 3 || mapData.FreeLen() != 0 {
+		t.Errorf("len --> %v, free len --> %v", mapData.Len(), mapData.FreeLen())
+	}
+}
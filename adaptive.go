@@ -0,0 +1,192 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveConfig 自适应分区数的参数，零值使用默认值
+type AdaptiveConfig struct {
+	Interval           time.Duration // 评估周期，默认 1s
+	GrowContention     float64       // 等锁的操作比例高于该值时扩容，默认 0.05
+	ShrinkOpsPerSecond float64       // 无竞争且每个分区每秒操作数低于该值时缩容，默认 10
+	MinPartitions      int           // 默认为创建时的 lenOfBucket
+	MaxPartitions      int           // 默认 4096
+}
+
+// AdaptiveDecision 一次评估的结果
+type AdaptiveDecision int
+
+const (
+	AdaptiveKeep AdaptiveDecision = iota
+	AdaptiveGrow
+	AdaptiveShrink
+)
+
+// AdaptiveStats reports what the adaptive mode measured during the last
+// interval and what it decided.
+type AdaptiveStats struct {
+	Partitions          int
+	Ops                 int64         // 最近一个周期的加锁次数
+	Contended           int64         // 其中需要等待的次数
+	WaitTime            time.Duration // 等待锁的总时间
+	HottestPartitionOps int64         // 操作最多的分区的加锁次数
+	LastDecision        AdaptiveDecision
+	LastDecisionAt      time.Time
+	Grows               int // 累计扩容次数
+	Shrinks             int // 累计缩容次数
+}
+
+// partitionStats 开启自适应模式后每个分区的计数，原子操作
+type partitionStats struct {
+	ops       int64
+	contended int64
+	waitNanos int64
+}
+
+type adaptiveState struct {
+	cfg  AdaptiveConfig
+	stop chan struct{}
+	last time.Time
+
+	mu    sync.Mutex
+	stats AdaptiveStats
+}
+
+// lockMeasured 先尝试加锁，失败时才计时，不竞争的操作几乎没有额外开销
+func (p *partition) lockMeasured(write bool) {
+	atomic.AddInt64(&p.stats.ops, 1)
+	if write && p.mu.TryLock() || !write && p.mu.TryRLock() {
+		return
+	}
+
+	start := time.Now()
+	p.lock(write)
+	atomic.AddInt64(&p.stats.contended, 1)
+	atomic.AddInt64(&p.stats.waitNanos, int64(time.Since(start)))
+}
+
+// EnableAdaptive samples partition lock contention and grows the partition
+// count when too many operations wait for a lock, or shrinks it when the map
+// is idle. Decisions are applied with Resize and reported by AdaptiveStats.
+// DisableAdaptive must be called to stop the background goroutine.
+func (m *concurrentMap) EnableAdaptive(cfg AdaptiveConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.GrowContention <= 0 {
+		cfg.GrowContention = 0.05
+	}
+	if cfg.ShrinkOpsPerSecond <= 0 {
+		cfg.ShrinkOpsPerSecond = 10
+	}
+	if cfg.MaxPartitions <= 0 {
+		cfg.MaxPartitions = 4096
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cfg.MinPartitions <= 0 {
+		cfg.MinPartitions = m.minBucket
+	}
+	if m.adaptive != nil {
+		close(m.adaptive.stop)
+	}
+	a := &adaptiveState{cfg: cfg, stop: make(chan struct{}), last: time.Now()}
+	a.stats.Partitions = m.lenOfBucket
+	m.adaptive = a
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.stop:
+				return
+			case now := <-ticker.C:
+				m.evaluate(a, now)
+			}
+		}
+	}()
+}
+
+// DisableAdaptive stops the adaptive mode, keeping the current partitions.
+func (m *concurrentMap) DisableAdaptive() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.adaptive != nil {
+		close(m.adaptive.stop)
+		m.adaptive = nil
+	}
+}
+
+// AdaptiveStats returns the measurements and decision of the last interval.
+func (m *concurrentMap) AdaptiveStats() AdaptiveStats {
+	m.mu.RLock()
+	a := m.adaptive
+	m.mu.RUnlock()
+
+	if a == nil {
+		return AdaptiveStats{Partitions: m.Partitions()}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats
+}
+
+// evaluate 汇总并清零各分区的计数，决定是否扩缩容
+func (m *concurrentMap) evaluate(a *adaptiveState, now time.Time) {
+	var ops, contended, waitNanos, hottest int64
+	m.mu.RLock()
+	n := m.lenOfBucket
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			o := atomic.SwapInt64(&p.stats.ops, 0)
+			ops += o
+			contended += atomic.SwapInt64(&p.stats.contended, 0)
+			waitNanos += atomic.SwapInt64(&p.stats.waitNanos, 0)
+			if o > hottest {
+				hottest = o
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	elapsed := now.Sub(a.last).Seconds()
+	a.last = now
+
+	decision := AdaptiveKeep
+	switch {
+	case ops > 0 && float64(contended)/float64(ops) > a.cfg.GrowContention && n < a.cfg.MaxPartitions:
+		decision = AdaptiveGrow
+		n *= 2
+		if n > a.cfg.MaxPartitions {
+			n = a.cfg.MaxPartitions
+		}
+	case contended == 0 && elapsed > 0 && float64(ops)/elapsed/float64(n) < a.cfg.ShrinkOpsPerSecond && n/2 >= a.cfg.MinPartitions:
+		decision = AdaptiveShrink
+		n /= 2
+	}
+	if decision != AdaptiveKeep {
+		m.Resize(n)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats.Partitions = n
+	a.stats.Ops = ops
+	a.stats.Contended = contended
+	a.stats.WaitTime = time.Duration(waitNanos)
+	a.stats.HottestPartitionOps = hottest
+	a.stats.LastDecision = decision
+	a.stats.LastDecisionAt = now
+	switch decision {
+	case AdaptiveGrow:
+		a.stats.Grows++
+	case AdaptiveShrink:
+		a.stats.Shrinks++
+	}
+}
@@ -0,0 +1,77 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveGrowShrink(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(8)
+	mapData.EnableAdaptive(AdaptiveConfig{Interval: time.Hour, MinPartitions: 2, MaxPartitions: 32})
+	defer mapData.DisableAdaptive()
+	a := mapData.adaptive
+
+	for i := 0; i < 1000; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	mapData.partitions[3].stats.contended = 100
+
+	mapData.evaluate(a, a.last.Add(time.Second))
+	stats := mapData.AdaptiveStats()
+	if stats.LastDecision != AdaptiveGrow || stats.Partitions != 16 || mapData.Partitions() != 16 {
+		t.Errorf("grow failed: %+v", stats)
+	}
+	if stats.Ops != 1000 || stats.Contended != 100 || stats.HottestPartitionOps == 0 {
+		t.Errorf("stats --> %+v", stats)
+	}
+
+	// 清零之后没有任何操作，判断为空闲
+	mapData.evaluate(a, a.last.Add(time.Second))
+	mapData.evaluate(a, a.last.Add(time.Second))
+	mapData.evaluate(a, a.last.Add(time.Second))
+	if stats = mapData.AdaptiveStats(); stats.LastDecision != AdaptiveShrink || stats.Partitions != 2 {
+		t.Errorf("shrink failed: %+v", stats)
+	}
+	mapData.evaluate(a, a.last.Add(time.Second))
+	stats = mapData.AdaptiveStats()
+	if stats.LastDecision != AdaptiveKeep || stats.Partitions != 2 || stats.Grows != 1 || stats.Shrinks != 3 {
+		t.Errorf("shrink failed: %+v", stats)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if v, ok := mapData.Get(StrKey(strconv.Itoa(i))); !ok || v.(int) != i {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestAdaptiveGoroutine(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(1)
+	mapData.EnableAdaptive(AdaptiveConfig{Interval: 5 * time.Millisecond, GrowContention: 0.001})
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20000; i++ {
+				key := StrKey(strconv.Itoa(g*20000 + i))
+				mapData.Set(key, i)
+				mapData.Get(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	mapData.DisableAdaptive()
+
+	if mapData.Len() != 8*20000 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+	stats := mapData.AdaptiveStats()
+	t.Logf("%+v", stats)
+	if stats.Partitions != mapData.Partitions() {
+		t.Errorf("stats after disable --> %+v", stats)
+	}
+}
@@ -0,0 +1,236 @@
+package HighPerformanceMap
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrValueConflict is returned by BiMap.Set under RejectConflict when the
+// value is already bound to another key.
+var ErrValueConflict = errors.New("HighPerformanceMap: value is bound to another key")
+
+// ConflictPolicy decides what BiMap.Set does when the value is already bound
+// to another key.
+type ConflictPolicy int
+
+const (
+	RejectConflict  ConflictPolicy = iota // 返回 ErrValueConflict，不做修改
+	ReplaceConflict                       // 删除原来的 key
+)
+
+// biMap 的正向和反向索引使用相同数量的分区，第 i 个分区的锁同时保护 forward[i] 和 inverse[i]，
+// 一次修改需要的分区锁按分区号从小到大加锁，不会死锁
+type biMap struct {
+	forward []*partition // key → biEntry，锁也保护同一位置的 inverse
+	inverse []*partition // value → biEntry，不使用自己的锁
+	policy  ConflictPolicy
+	count   int64 // 原子操作
+}
+
+// biEntry 正反两个索引共用，创建后不再修改
+type biEntry struct {
+	keyIndex   uint64
+	key        any
+	valueIndex uint64
+	value      any
+}
+
+// CreateBiMap creates a one-to-one map that can be looked up by key or by
+// value. policy decides what happens when Set binds a value that already
+// belongs to another key.
+func CreateBiMap(lenOfBucket int, policy ConflictPolicy) *biMap {
+	return &biMap{
+		forward: newPartitions(lenOfBucket, BuiltinMapBackend),
+		inverse: newPartitions(lenOfBucket, BuiltinMapBackend),
+		policy:  policy,
+	}
+}
+
+func (b *biMap) stripe(keyIndex uint64) int {
+	return int(keyIndex % uint64(len(b.forward)))
+}
+
+// lockStripes 去重排序后依次加写锁
+func (b *biMap) lockStripes(stripes []int) []int {
+	sort.Ints(stripes)
+	held := make([]int, 0, len(stripes))
+	for i, s := range stripes {
+		if i == 0 || s != stripes[i-1] {
+			held = append(held, s)
+			b.forward[s].mu.Lock()
+		}
+	}
+	return held
+}
+
+func (b *biMap) unlockStripes(held []int) {
+	for _, s := range held {
+		b.forward[s].mu.Unlock()
+	}
+}
+
+func holdsStripe(held []int, s int) bool {
+	for _, h := range held {
+		if h == s {
+			return true
+		}
+	}
+	return false
+}
+
+// find 在 forward 或 inverse 中查找，调用方持有所在分区的锁
+func (b *biMap) find(partitions []*partition, keyIndex uint64, key any) *biEntry {
+	p := partitions[b.stripe(keyIndex)]
+	if index, _, ok := p.lookupFunc(keyIndex, setKey{keyIndex, key}.equal); ok {
+		return (*biEntry)(p.innerSlice[index].Value)
+	}
+	return nil
+}
+
+func (b *biMap) insert(e *biEntry) {
+	b.forward[b.stripe(e.keyIndex)].insert(e.keyIndex, &innerSlice{key: e.key, Value: unsafe.Pointer(e)})
+	b.inverse[b.stripe(e.valueIndex)].insert(e.valueIndex, &innerSlice{key: e.value, Value: unsafe.Pointer(e)})
+	atomic.AddInt64(&b.count, 1)
+}
+
+func (b *biMap) remove(e *biEntry) {
+	for _, k := range []struct {
+		partitions []*partition
+		keyIndex   uint64
+		key        any
+	}{{b.forward, e.keyIndex, e.key}, {b.inverse, e.valueIndex, e.value}} {
+		p := k.partitions[b.stripe(k.keyIndex)]
+		if index, prev, ok := p.lookupFunc(k.keyIndex, setKey{k.keyIndex, k.key}.equal); ok {
+			p.unlink(k.keyIndex, index, prev)
+		}
+	}
+	atomic.AddInt64(&b.count, -1)
+}
+
+// Set binds key and value, replacing the previous value of key. If value
+// is bound to another key, the policy decides whether that binding is
+// replaced or ErrValueConflict is returned.
+func (b *biMap) Set(key, value Partitionable) error {
+	e := &biEntry{key.PartitionKey(), key.Value(), value.PartitionKey(), value.Value()}
+
+	// 先锁 key 和 value 所在的分区，读出原来的绑定后，
+	// 如果还需要其它分区，重新按顺序加锁再读一次
+	held := b.lockStripes([]int{b.stripe(e.keyIndex), b.stripe(e.valueIndex)})
+	var oldByKey, oldByValue *biEntry
+	for {
+		oldByKey = b.find(b.forward, e.keyIndex, e.key)
+		oldByValue = b.find(b.inverse, e.valueIndex, e.value)
+		need := []int{b.stripe(e.keyIndex), b.stripe(e.valueIndex)}
+		if oldByKey != nil {
+			need = append(need, b.stripe(oldByKey.valueIndex))
+		}
+		if oldByValue != nil {
+			need = append(need, b.stripe(oldByValue.keyIndex))
+		}
+		ok := true
+		for _, s := range need {
+			ok = ok && holdsStripe(held, s)
+		}
+		if ok {
+			break
+		}
+		b.unlockStripes(held)
+		held = b.lockStripes(need)
+	}
+	defer b.unlockStripes(held)
+
+	if oldByKey != nil && oldByKey == oldByValue {
+		return nil
+	}
+	if oldByValue != nil {
+		if b.policy == RejectConflict {
+			return ErrValueConflict
+		}
+		b.remove(oldByValue)
+	}
+	if oldByKey != nil {
+		b.remove(oldByKey)
+	}
+	b.insert(e)
+	return nil
+}
+
+// get 加读锁查找，返回的 biEntry 不会被修改
+func (b *biMap) get(partitions []*partition, key Partitionable) *biEntry {
+	keyIndex := key.PartitionKey()
+	mu := &b.forward[b.stripe(keyIndex)].mu
+	mu.RLock()
+	defer mu.RUnlock()
+	return b.find(partitions, keyIndex, key.Value())
+}
+
+// GetByKey returns the Value of the value bound to key.
+func (b *biMap) GetByKey(key Partitionable) (any, bool) {
+	if e := b.get(b.forward, key); e != nil {
+		return e.value, true
+	}
+	return nil, false
+}
+
+// GetByValue returns the Value of the key bound to value.
+func (b *biMap) GetByValue(value Partitionable) (any, bool) {
+	if e := b.get(b.inverse, value); e != nil {
+		return e.key, true
+	}
+	return nil, false
+}
+
+// deleteBy 删除 partitions 中 key 对应的绑定，另一侧的分区不同时重新加锁
+func (b *biMap) deleteBy(partitions []*partition, key Partitionable) bool {
+	keyIndex := key.PartitionKey()
+	held := b.lockStripes([]int{b.stripe(keyIndex)})
+	var e *biEntry
+	for {
+		if e = b.find(partitions, keyIndex, key.Value()); e == nil {
+			break
+		}
+		if holdsStripe(held, b.stripe(e.keyIndex)) && holdsStripe(held, b.stripe(e.valueIndex)) {
+			b.remove(e)
+			break
+		}
+		b.unlockStripes(held)
+		held = b.lockStripes([]int{b.stripe(e.keyIndex), b.stripe(e.valueIndex)})
+	}
+	b.unlockStripes(held)
+	return e != nil
+}
+
+// DeleteByKey removes the binding of key and reports whether it existed.
+func (b *biMap) DeleteByKey(key Partitionable) bool {
+	return b.deleteBy(b.forward, key)
+}
+
+// DeleteByValue removes the binding of value and reports whether it existed.
+func (b *biMap) DeleteByValue(value Partitionable) bool {
+	return b.deleteBy(b.inverse, value)
+}
+
+func (b *biMap) Len() int {
+	return int(atomic.LoadInt64(&b.count))
+}
+
+// Range 复制所有绑定后再调用 f，f 中可以修改 map
+func (b *biMap) Range(f func(key, value any) bool) {
+	var entries []*biEntry
+	for _, p := range b.forward {
+		p.mu.RLock()
+		p.rangeData(func(data *innerSlice) bool {
+			entries = append(entries, (*biEntry)(data.Value))
+			return true
+		})
+		p.mu.RUnlock()
+	}
+
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
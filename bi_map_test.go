@@ -0,0 +1,85 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBiMap(t *testing.T) {
+	b := CreateBiMap(4, RejectConflict)
+	if err := b.Set(StrKey("s1"), I64Key(1)); err != nil {
+		t.Fatal(err)
+	}
+	b.Set(StrKey("s2"), I64Key(2))
+	if err := b.Set(StrKey("s3"), I64Key(1)); err != ErrValueConflict {
+		t.Errorf("conflict --> %v", err)
+	}
+	if err := b.Set(StrKey("s1"), I64Key(1)); err != nil {
+		t.Errorf("same binding --> %v", err)
+	}
+
+	if v, ok := b.GetByKey(StrKey("s1")); !ok || v.(uint64) != 1 {
+		t.Errorf("get by key --> %v, %v", v, ok)
+	}
+	if k, ok := b.GetByValue(I64Key(2)); !ok || k.(string) != "s2" {
+		t.Errorf("get by value --> %v, %v", k, ok)
+	}
+
+	// key 换绑新的 value，旧的 value 不再有反向索引
+	b.Set(StrKey("s1"), I64Key(3))
+	if _, ok := b.GetByValue(I64Key(1)); ok || b.Len() != 2 {
+		t.Errorf("old value still bound, len --> %v", b.Len())
+	}
+	if !b.DeleteByValue(I64Key(3)) || b.DeleteByValue(I64Key(3)) {
+		t.Error("delete by value")
+	}
+	if _, ok := b.GetByKey(StrKey("s1")); ok || b.Len() != 1 {
+		t.Errorf("key still bound, len --> %v", b.Len())
+	}
+
+	r := CreateBiMap(4, ReplaceConflict)
+	r.Set(StrKey("s1"), I64Key(1))
+	if err := r.Set(StrKey("s2"), I64Key(1)); err != nil {
+		t.Errorf("replace --> %v", err)
+	}
+	if k, _ := r.GetByValue(I64Key(1)); k.(string) != "s2" || r.Len() != 1 {
+		t.Errorf("replace --> %v, len %v", k, r.Len())
+	}
+	if _, ok := r.GetByKey(StrKey("s1")); ok || !r.DeleteByKey(StrKey("s2")) || r.Len() != 0 {
+		t.Error("delete by key")
+	}
+}
+
+func TestBiMapGoroutine(t *testing.T) {
+	b := CreateBiMap(8, ReplaceConflict)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := StrKey(strconv.Itoa((g*7 + i) % 100))
+				value := I64Key(int64(i % 50))
+				b.Set(key, value)
+				if i%5 == 0 {
+					b.DeleteByValue(I64Key(int64(i % 30)))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// 正反索引一致
+	n := 0
+	b.Range(func(key, value any) bool {
+		n++
+		if k, ok := b.GetByValue(I64Key(int64(value.(uint64)))); !ok || k != key {
+			t.Errorf("inverse of %v --> %v, %v", key, k, ok)
+		}
+		return true
+	})
+	if n != b.Len() || n > 50 {
+		t.Errorf("range --> %v, len --> %v", n, b.Len())
+	}
+}
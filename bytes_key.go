@@ -0,0 +1,31 @@
+package HighPerformanceMap
+
+import (
+	"hash/crc64"
+)
+
+// bytesKey 不复制 []byte，只在写入 map 时通过 Value 转成 string 保存，
+// 因此 BytesKey 与内容相同的 StrKey 是同一个 key
+type bytesKey struct {
+	key   uint64
+	value []byte
+}
+
+func (b *bytesKey) PartitionKey() uint64 {
+	return b.key
+}
+
+func (b *bytesKey) Value() any {
+	return string(b.value)
+}
+
+func (b *bytesKey) equal(stored any) bool {
+	v, ok := stored.(string)
+	return ok && v == string(b.value)
+}
+
+// BytesKey hashes key without converting it to a string. key must not be
+// modified while the returned key is in use.
+func BytesKey(key []byte) *bytesKey {
+	return &bytesKey{crc64.Checksum(key, crcTable), key}
+}
@@ -0,0 +1,179 @@
+package HighPerformanceMap
+
+import (
+	"sync/atomic"
+)
+
+// concurrentSet 使用 concurrentMap 的分区只保存 key，innerSlice.Value 始终为 nil
+type concurrentSet struct {
+	m *concurrentMap
+}
+
+// setKey 集合运算时使用的 key，key 为 Partitionable.Value 的结果
+type setKey struct {
+	keyIndex uint64
+	key      any
+}
+
+// CreateConcurrentSet creates a set of keys stored without values.
+func CreateConcurrentSet(lenOfBucket int) *concurrentSet {
+	return &concurrentSet{m: CreateConcurrentSliceMap(lenOfBucket)}
+}
+
+// Add adds key to the set.
+func (s *concurrentSet) Add(key Partitionable) {
+	s.AddIfAbsent(key)
+}
+
+// AddIfAbsent adds key and reports whether it was not in the set before.
+func (s *concurrentSet) AddIfAbsent(key Partitionable) bool {
+	return s.add(setKey{key.PartitionKey(), key.Value()})
+}
+
+// Remove removes key and reports whether it was in the set.
+func (s *concurrentSet) Remove(key Partitionable) bool {
+	return s.remove(setKey{key.PartitionKey(), key.Value()})
+}
+
+func (s *concurrentSet) Contains(key Partitionable) bool {
+	return s.contains(setKey{key.PartitionKey(), key.Value()})
+}
+
+func (s *concurrentSet) Len() int {
+	return s.m.Len()
+}
+
+func (k setKey) equal(stored any) bool {
+	return stored == k.key
+}
+
+func (s *concurrentSet) add(k setKey) bool {
+	m := s.m
+	m.mu.RLock()
+	p := m.lockPartition(k.keyIndex, true)
+	_, _, ok := p.lookupFunc(k.keyIndex, k.equal)
+	if !ok {
+		p.insert(k.keyIndex, &innerSlice{key: k.key})
+		atomic.AddInt64(&m.count, 1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.rehash()
+	return !ok
+}
+
+func (s *concurrentSet) remove(k setKey) bool {
+	m := s.m
+	m.mu.RLock()
+	p := m.lockPartition(k.keyIndex, true)
+	index, prev, ok := p.lookupFunc(k.keyIndex, k.equal)
+	if ok {
+		p.unlink(k.keyIndex, index, prev)
+		atomic.AddInt64(&m.count, -1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.rehash()
+	return ok
+}
+
+func (s *concurrentSet) contains(k setKey) bool {
+	m := s.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(k.keyIndex, false)
+	defer p.mu.RUnlock()
+
+	_, _, ok := p.lookupFunc(k.keyIndex, k.equal)
+	return ok
+}
+
+// Range calls f for every key's Value until f returns false.
+func (s *concurrentSet) Range(f func(key any) bool) {
+	s.m.rangeData(func(data *innerSlice) bool {
+		return f(data.key)
+	})
+}
+
+// keys 复制集合中所有的 key，集合运算不会同时持有两个集合的锁
+func (s *concurrentSet) keys() []setKey {
+	m := s.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.rehashMu.RLock()
+	defer m.rehashMu.RUnlock()
+
+	keys := make([]setKey, 0, m.Len())
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.mu.RLock()
+			if !p.migrated {
+				p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+					for ; index >= 0; index = int(p.innerSlice[index].next) {
+						keys = append(keys, setKey{keyIndex, p.innerSlice[index].key})
+					}
+					return true
+				})
+			}
+			p.mu.RUnlock()
+		}
+	}
+	return keys
+}
+
+func (s *concurrentSet) newSet() *concurrentSet {
+	return CreateConcurrentSet(s.m.minBucket)
+}
+
+// Union returns a new set with the keys in s or other.
+func (s *concurrentSet) Union(other *concurrentSet) *concurrentSet {
+	u := s.newSet()
+	u.UnionWith(s)
+	u.UnionWith(other)
+	return u
+}
+
+// Intersect returns a new set with the keys in both s and other.
+func (s *concurrentSet) Intersect(other *concurrentSet) *concurrentSet {
+	u := s.newSet()
+	for _, k := range s.keys() {
+		if other.contains(k) {
+			u.add(k)
+		}
+	}
+	return u
+}
+
+// Difference returns a new set with the keys in s but not in other.
+func (s *concurrentSet) Difference(other *concurrentSet) *concurrentSet {
+	u := s.newSet()
+	for _, k := range s.keys() {
+		if !other.contains(k) {
+			u.add(k)
+		}
+	}
+	return u
+}
+
+// UnionWith adds every key of other to s.
+func (s *concurrentSet) UnionWith(other *concurrentSet) {
+	for _, k := range other.keys() {
+		s.add(k)
+	}
+}
+
+// IntersectWith removes the keys of s that are not in other.
+func (s *concurrentSet) IntersectWith(other *concurrentSet) {
+	for _, k := range s.keys() {
+		if !other.contains(k) {
+			s.remove(k)
+		}
+	}
+}
+
+// DifferenceWith removes the keys of other from s.
+func (s *concurrentSet) DifferenceWith(other *concurrentSet) {
+	for _, k := range other.keys() {
+		s.remove(k)
+	}
+}
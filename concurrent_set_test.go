@@ -0,0 +1,101 @@
+package HighPerformanceMap
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func setInts(s *concurrentSet) []int {
+	var keys []int
+	s.Range(func(key any) bool {
+		keys = append(keys, int(key.(uint64)))
+		return true
+	})
+	sort.Ints(keys)
+	return keys
+}
+
+func newIntSet(keys ...int) *concurrentSet {
+	s := CreateConcurrentSet(4)
+	for _, k := range keys {
+		s.Add(I64Key(int64(k)))
+	}
+	return s
+}
+
+func TestConcurrentSet(t *testing.T) {
+	s := newIntSet(1, 2, 3)
+	if !s.AddIfAbsent(I64Key(4)) || s.AddIfAbsent(I64Key(4)) {
+		t.Error("add if absent")
+	}
+	if !s.Remove(I64Key(1)) || s.Remove(I64Key(1)) {
+		t.Error("remove")
+	}
+	if s.Contains(I64Key(1)) || !s.Contains(I64Key(2)) || s.Len() != 3 {
+		t.Errorf("contains, len --> %v", s.Len())
+	}
+	s.Add(StrKey("a"))
+	if !s.Contains(StrKey("a")) || s.Contains(StrKey("b")) {
+		t.Error("string key")
+	}
+	s.Remove(StrKey("a"))
+
+	other := newIntSet(3, 4, 5)
+	check := func(name string, got *concurrentSet, want ...int) {
+		if keys := setInts(got); !reflect.DeepEqual(keys, want) || got.Len() != len(want) {
+			t.Errorf("%v --> %v, len %v, want %v", name, keys, got.Len(), want)
+		}
+	}
+	check("union", s.Union(other), 2, 3, 4, 5)
+	check("intersect", s.Intersect(other), 3, 4)
+	check("difference", s.Difference(other), 2)
+	check("unchanged", s, 2, 3, 4)
+
+	s.UnionWith(other)
+	check("union with", s, 2, 3, 4, 5)
+	s.IntersectWith(newIntSet(2, 3, 9))
+	check("intersect with", s, 2, 3)
+	s.DifferenceWith(newIntSet(3))
+	check("difference with", s, 2)
+}
+
+func TestConcurrentSetGoroutine(t *testing.T) {
+	s := CreateConcurrentSet(2)
+	s.m.SetResizePolicy(1, 8)
+	a, b := newIntSet(), newIntSet()
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !s.AddIfAbsent(I64Key(int64(g*1000 + i))) {
+					t.Errorf("add %v failed", g*1000+i)
+				}
+				a.Add(I64Key(int64(i)))
+			}
+		}(g)
+	}
+	// 两个集合互相做集合运算不会死锁
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			a.UnionWith(b)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			b.UnionWith(a)
+		}
+	}()
+	wg.Wait()
+
+	if s.Len() != 4000 || a.Len() != 1000 {
+		t.Errorf("len --> %v, %v", s.Len(), a.Len())
+	}
+}
@@ -0,0 +1,176 @@
+package HighPerformanceMap
+
+import (
+	"container/heap"
+	"sort"
+	"sync/atomic"
+	"unsafe"
+)
+
+// counterMap 使用 concurrentMap 的分区保存计数，innerSlice.Value 指向 int64 而不是 any，
+// key 已存在时只加分区的读锁，用原子操作修改计数
+type counterMap struct {
+	m *concurrentMap
+}
+
+// Counter is one entry returned by TopN.
+type Counter struct {
+	Key   any
+	Value int64
+}
+
+// CreateCounterMap creates a map of int64 counters that are updated with
+// atomic operations, so concurrent Add calls on the same key never race.
+func CreateCounterMap(lenOfBucket int) *counterMap {
+	return &counterMap{m: CreateConcurrentSliceMap(lenOfBucket)}
+}
+
+func counterValue(data *innerSlice) *int64 {
+	return (*int64)(data.Value)
+}
+
+// Add adds delta to the counter of key, creating it at 0 if missing, and
+// returns the new value.
+func (c *counterMap) Add(key Partitionable, delta int64) int64 {
+	keyIndex := key.PartitionKey()
+	m := c.m
+
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, false)
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		n := atomic.AddInt64(counterValue(p.innerSlice[index]), delta)
+		p.mu.RUnlock()
+		m.mu.RUnlock()
+		return n
+	}
+	p.mu.RUnlock()
+
+	// key 不存在，加写锁后再查一次
+	p = m.lockPartition(keyIndex, true)
+	var n int64
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		n = atomic.AddInt64(counterValue(p.innerSlice[index]), delta)
+	} else {
+		n = delta
+		v := new(int64)
+		*v = delta
+		p.insert(keyIndex, &innerSlice{key: key.Value(), Value: unsafe.Pointer(v)})
+		atomic.AddInt64(&m.count, 1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.rehash()
+	return n
+}
+
+func (c *counterMap) Inc(key Partitionable) int64 {
+	return c.Add(key, 1)
+}
+
+func (c *counterMap) Dec(key Partitionable) int64 {
+	return c.Add(key, -1)
+}
+
+func (c *counterMap) Get(key Partitionable) (int64, bool) {
+	keyIndex := key.PartitionKey()
+	c.m.mu.RLock()
+	defer c.m.mu.RUnlock()
+	p := c.m.lockPartition(keyIndex, false)
+	defer p.mu.RUnlock()
+
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		return atomic.LoadInt64(counterValue(p.innerSlice[index])), true
+	}
+	return 0, false
+}
+
+// Reset sets the counter of key to 0 and returns its previous value. The
+// key is kept; use Delete to remove it.
+func (c *counterMap) Reset(key Partitionable) int64 {
+	keyIndex := key.PartitionKey()
+	c.m.mu.RLock()
+	defer c.m.mu.RUnlock()
+	p := c.m.lockPartition(keyIndex, false)
+	defer p.mu.RUnlock()
+
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		return atomic.SwapInt64(counterValue(p.innerSlice[index]), 0)
+	}
+	return 0
+}
+
+func (c *counterMap) Delete(key Partitionable) {
+	keyIndex := key.PartitionKey()
+	m := c.m
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, true)
+	if _, ok := p.delete(keyIndex, key); ok {
+		atomic.AddInt64(&m.count, -1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.rehash()
+}
+
+func (c *counterMap) Len() int {
+	return c.m.Len()
+}
+
+// Range 遍历所有计数，f 执行时持有所在分区的读锁
+func (c *counterMap) Range(f func(key any, value int64) bool) {
+	c.m.rangeData(func(data *innerSlice) bool {
+		return f(data.key, atomic.LoadInt64(counterValue(data)))
+	})
+}
+
+// Snapshot returns a copy of every counter, keyed by the key's Value.
+// Counters updated during the call may or may not include the update.
+func (c *counterMap) Snapshot() map[any]int64 {
+	snapshot := make(map[any]int64, c.Len())
+	c.Range(func(key any, value int64) bool {
+		snapshot[key] = value
+		return true
+	})
+	return snapshot
+}
+
+// counterHeap 最小堆，堆顶是目前前 n 个中最小的计数
+type counterHeap []Counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].Value < h[j].Value }
+func (h counterHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *counterHeap) Push(x any)        { *h = append(*h, x.(Counter)) }
+func (h *counterHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// TopN returns the n largest counters in descending order.
+func (c *counterMap) TopN(n int) []Counter {
+	if n <= 0 {
+		return nil
+	}
+
+	size := n
+	if l := c.Len(); l < size {
+		size = l
+	}
+	h := make(counterHeap, 0, size)
+	c.Range(func(key any, value int64) bool {
+		switch {
+		case len(h) < n:
+			heap.Push(&h, Counter{key, value})
+		case value > h[0].Value:
+			h[0] = Counter{key, value}
+			heap.Fix(&h, 0)
+		}
+		return true
+	})
+	sort.Slice(h, func(i, j int) bool {
+		return h[i].Value > h[j].Value
+	})
+	return h
+}
@@ -0,0 +1,68 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCounterMap(t *testing.T) {
+	counters := CreateCounterMap(4)
+	for i := 0; i < 10; i++ {
+		counters.Add(StrKey(strconv.Itoa(i)), int64(i))
+	}
+	if n := counters.Inc(StrKey("3")); n != 4 {
+		t.Errorf("inc --> %v", n)
+	}
+	if n := counters.Dec(StrKey("new")); n != -1 {
+		t.Errorf("dec --> %v", n)
+	}
+	if n := counters.Reset(StrKey("9")); n != 9 {
+		t.Errorf("reset --> %v", n)
+	}
+	if n, ok := counters.Get(StrKey("9")); !ok || n != 0 {
+		t.Errorf("get after reset --> %v, %v", n, ok)
+	}
+	counters.Delete(StrKey("0"))
+	if _, ok := counters.Get(StrKey("0")); ok || counters.Len() != 10 {
+		t.Errorf("delete --> %v, len --> %v", ok, counters.Len())
+	}
+
+	top := counters.TopN(3)
+	if len(top) != 3 || top[0] != (Counter{"8", 8}) || top[1] != (Counter{"7", 7}) || top[2] != (Counter{"6", 6}) {
+		t.Errorf("top --> %v", top)
+	}
+	if top := counters.TopN(100); len(top) != 10 || top[9].Value != -1 {
+		t.Errorf("top all --> %v", top)
+	}
+
+	snapshot := counters.Snapshot()
+	if len(snapshot) != 10 || snapshot["3"] != 4 || snapshot["new"] != -1 {
+		t.Errorf("snapshot --> %v", snapshot)
+	}
+}
+
+func TestCounterMapGoroutine(t *testing.T) {
+	counters := CreateCounterMap(2)
+	counters.m.SetResizePolicy(1, 4)
+	goroutineNum := 8
+	num := 10000
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < goroutineNum; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				counters.Inc(I64Key(int64(i % 100)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 100; i++ {
+		if n, _ := counters.Get(I64Key(int64(i))); n != int64(goroutineNum*num/100) {
+			t.Fatalf("counter %v --> %v", i, n)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package HighPerformanceMap
+
+// cuckooIndex CuckooBackend 使用的 slotIndex：两个 hash 函数各选一个桶，每个桶 4 个位置，
+// keyIndex 只可能在这两个桶中，查找最多比较 8 个位置。分区中 keyIndex 不会重复，
+// 不需要 cuckooMap 的溢出链表。两个桶都满时沿 cuckoo 路径移动其它 keyIndex，找不到路径时扩容
+type cuckooIndex struct {
+	buckets []cuckooSlots
+	used    int
+}
+
+type cuckooSlots [cuckooBucketSize]cuckooSlot
+
+// cuckooSlot 的 index 为链表头的位置加一，0 表示空位
+type cuckooSlot struct {
+	keyIndex uint64
+	index    int
+}
+
+func newCuckooIndex() *cuckooIndex {
+	return &cuckooIndex{buckets: make([]cuckooSlots, 2)}
+}
+
+// cuckooBuckets 两个 hash 函数选出的桶，n 为 2 的幂
+func cuckooBuckets(keyIndex uint64, n int) (int, int) {
+	mask := uint64(n - 1)
+	h := mix64(keyIndex)
+	return int(h & mask), int(mix64(h) & mask)
+}
+
+// alternate 返回 keyIndex 在 b 之外的另一个桶
+func (c *cuckooIndex) alternate(keyIndex uint64, b int) int {
+	b1, b2 := cuckooBuckets(keyIndex, len(c.buckets))
+	if b == b1 {
+		return b2
+	}
+	return b1
+}
+
+func (c *cuckooIndex) find(keyIndex uint64) *cuckooSlot {
+	b1, b2 := cuckooBuckets(keyIndex, len(c.buckets))
+	for _, b := range [2]int{b1, b2} {
+		bucket := &c.buckets[b]
+		for i := range bucket {
+			if s := &bucket[i]; s.index != 0 && s.keyIndex == keyIndex {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func (c *cuckooIndex) get(keyIndex uint64) (int, bool) {
+	if s := c.find(keyIndex); s != nil {
+		return s.index - 1, true
+	}
+	return 0, false
+}
+
+func (c *cuckooIndex) set(keyIndex uint64, index int) {
+	if s := c.find(keyIndex); s != nil {
+		s.index = index + 1
+		return
+	}
+	for !c.insert(keyIndex, index+1) {
+		c.grow()
+	}
+	c.used++
+}
+
+// insert 把不存在的 keyIndex 放到两个桶中的空位，需要时沿 cuckoo 路径移动其它 keyIndex，
+// 找不到路径时返回 false
+func (c *cuckooIndex) insert(keyIndex uint64, index int) bool {
+	b1, b2 := cuckooBuckets(keyIndex, len(c.buckets))
+	nodes := []cuckooNode{{bucket: b1, parent: -1}, {bucket: b2, parent: -1}}
+	for i := 0; i < len(nodes) && len(nodes) < cuckooMaxSearch; i++ {
+		node := nodes[i]
+		if free := c.freeSlot(node.bucket); free != nil {
+			if free = c.movePath(nodes, i, free); free == nil {
+				return false
+			}
+			*free = cuckooSlot{keyIndex, index}
+			return true
+		}
+		if node.depth >= cuckooMaxPath {
+			continue
+		}
+		for slot, s := range c.buckets[node.bucket] {
+			nodes = append(nodes, cuckooNode{
+				bucket: c.alternate(s.keyIndex, node.bucket),
+				parent: i,
+				slot:   slot,
+				depth:  node.depth + 1,
+			})
+		}
+	}
+	return false
+}
+
+func (c *cuckooIndex) freeSlot(b int) *cuckooSlot {
+	bucket := &c.buckets[b]
+	for i := range bucket {
+		if bucket[i].index == 0 {
+			return &bucket[i]
+		}
+	}
+	return nil
+}
+
+// movePath 与 cuckooTable.movePath 相同，从路径末尾开始把每个 keyIndex 移到它的另一个桶，
+// 返回根节点的桶中腾出的位置，路径失效时返回 nil
+func (c *cuckooIndex) movePath(nodes []cuckooNode, i int, free *cuckooSlot) *cuckooSlot {
+	for node := nodes[i]; node.parent >= 0; node = nodes[node.parent] {
+		src := &c.buckets[nodes[node.parent].bucket][node.slot]
+		if src.index == 0 || free.index != 0 || c.alternate(src.keyIndex, nodes[node.parent].bucket) != node.bucket {
+			return nil
+		}
+		*free, *src = *src, cuckooSlot{}
+		free = src
+	}
+	return free
+}
+
+// grow 桶数加倍后重新插入，重新插入失败时继续加倍
+func (c *cuckooIndex) grow() {
+	old := c.buckets
+	for n := len(old) * 2; ; n *= 2 {
+		c.buckets = make([]cuckooSlots, n)
+		if c.copyFrom(old) {
+			return
+		}
+	}
+}
+
+func (c *cuckooIndex) copyFrom(old []cuckooSlots) bool {
+	for b := range old {
+		for _, s := range old[b] {
+			if s.index != 0 && !c.insert(s.keyIndex, s.index) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *cuckooIndex) delete(keyIndex uint64) {
+	if s := c.find(keyIndex); s != nil {
+		*s = cuckooSlot{}
+		c.used--
+	}
+}
+
+func (c *cuckooIndex) len() int {
+	return c.used
+}
+
+func (c *cuckooIndex) rangeIndex(f func(keyIndex uint64, index int) bool) {
+	c.rangeFrom(0, f)
+}
+
+// rangeFrom 从第 start % 桶数 个桶开始遍历，到最后一个桶之后回到第 0 个桶
+func (c *cuckooIndex) rangeFrom(start uint64, f func(keyIndex uint64, index int) bool) {
+	first := int(start % uint64(len(c.buckets)))
+	for i := range c.buckets {
+		bucket := &c.buckets[(first+i)%len(c.buckets)]
+		for _, s := range bucket {
+			if s.index != 0 && !f(s.keyIndex, s.index-1) {
+				return
+			}
+		}
+	}
+}
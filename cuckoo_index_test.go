@@ -0,0 +1,123 @@
+package HighPerformanceMap
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCuckooIndex(t *testing.T) {
+	c := newCuckooIndex()
+	want := make(map[uint64]int)
+	r := rand.New(rand.NewSource(1))
+
+	// key 范围小，反复删除和写入以覆盖 cuckoo 路径上的移动和扩容
+	for i := 0; i < 200000; i++ {
+		keyIndex := uint64(r.Intn(3000))
+		switch r.Intn(3) {
+		case 0:
+			c.set(keyIndex, i)
+			want[keyIndex] = i
+		case 1:
+			c.delete(keyIndex)
+			delete(want, keyIndex)
+		default:
+			index, ok := c.get(keyIndex)
+			if w, wok := want[keyIndex]; ok != wok || ok && index != w {
+				t.Fatalf("get %v --> %v, %v, want %v, %v", keyIndex, index, ok, w, wok)
+			}
+		}
+	}
+
+	if c.len() != len(want) {
+		t.Errorf("len --> %v, want %v", c.len(), len(want))
+	}
+	n := 0
+	c.rangeIndex(func(keyIndex uint64, index int) bool {
+		n++
+		if want[keyIndex] != index {
+			t.Errorf("range %v --> %v", keyIndex, index)
+		}
+		return true
+	})
+	if n != len(want) {
+		t.Errorf("range --> %v", n)
+	}
+}
+
+func TestCuckooIndexLoad(t *testing.T) {
+	c := newCuckooIndex()
+	num := 100000
+	for i := 0; i < num; i++ {
+		c.set(uint64(i)*7919, i)
+	}
+	// 每个 keyIndex 只在两个桶中
+	for i := 0; i < num; i++ {
+		keyIndex := uint64(i) * 7919
+		b1, b2 := cuckooBuckets(keyIndex, len(c.buckets))
+		found := false
+		for _, b := range [2]int{b1, b2} {
+			for _, s := range c.buckets[b] {
+				found = found || s.index == i+1 && s.keyIndex == keyIndex
+			}
+		}
+		if !found {
+			t.Fatalf("key %v not in its buckets", keyIndex)
+		}
+	}
+	if load := float64(num) / float64(len(c.buckets)*cuckooBucketSize); load < 0.3 {
+		t.Errorf("load --> %v", load)
+	}
+}
+
+func TestCuckooBackend(t *testing.T) {
+	num := 10000
+	mapData := CreateConcurrentSliceMapWithBackend(4, CuckooBackend)
+	mapData.SetResizePolicy(64, 1024)
+	for i := 0; i < num; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	for i := 0; i < num; i += 2 {
+		mapData.Delete(StrKey(strconv.Itoa(i)))
+	}
+	mapData.EnableLockFreeReads()
+	mapData.Resize(8)
+
+	for i := 0; i < num; i++ {
+		v, ok := mapData.GetString(strconv.Itoa(i))
+		if i%2 == 0 && ok || i%2 == 1 && (!ok || v.(int) != i) {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if mapData.Len() != num/2 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
+
+func TestCuckooBackendConcurrent(t *testing.T) {
+	mapData := CreateConcurrentSliceMapWithBackend(8, CuckooBackend)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := I64Key(int64(g*2000 + i))
+				mapData.Set(key, i)
+				if v, ok := mapData.Get(key); !ok || v.(int) != i {
+					t.Errorf("get %v --> %v, %v", key, v, ok)
+					return
+				}
+				if i%3 == 0 {
+					mapData.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	want := 8 * (2000 - 667)
+	if mapData.Len() != want {
+		t.Errorf("len --> %v, want %v", mapData.Len(), want)
+	}
+}
@@ -0,0 +1,476 @@
+package HighPerformanceMap
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// cuckooMap 参照 libcuckoo 的并发 cuckoo hash：每个 key 只可能在两个桶中，
+// 查找最多比较 2*cuckooBucketSize 个位置。写操作加两个桶所在分段的锁，
+// 读操作不加锁，通过分段的版本号判断读取期间是否有写入，有则重试。
+// 两个桶都由 hash 决定，hash 相同的 key 在同一对桶中，扩容不能把它们分开，
+// 所以两个桶中 hash 相同的 key 超过一个桶的大小后，其余的放入第一个桶的溢出链表
+type cuckooMap struct {
+	table unsafe.Pointer // *cuckooTable，扩容时整体替换
+	count int64          // key 的数量，原子操作
+}
+
+type cuckooTable struct {
+	buckets  []cuckooBucket
+	overflow []unsafe.Pointer // 与 buckets 对应的溢出链表 *viewNode，链表发布后不再修改，原子操作
+	stripes  []cuckooStripe   // 桶 b 由 stripes[b%len(stripes)] 保护
+	moved    int32            // 1 表示已被新表替换，原子操作
+}
+
+const (
+	cuckooBucketSize = 4
+	cuckooStripes    = 64
+	cuckooMaxPath    = 5   // 查找 cuckoo 路径的最大深度
+	cuckooMaxSearch  = 512 // 广度优先搜索最多访问的桶数
+)
+
+type cuckooBucket [cuckooBucketSize]cuckooCell
+
+// cuckooCell 的 data 为 nil 表示空位，data 指向的 innerSlice 发布后不再修改
+type cuckooCell struct {
+	keyIndex uint64
+	data     unsafe.Pointer // *innerSlice
+}
+
+// cuckooStripe 的 seq 为奇数时表示正在写入
+type cuckooStripe struct {
+	mu  sync.Mutex
+	seq uint64
+	_   [40]byte // 避免相邻分段的伪共享
+}
+
+// CreateCuckooMap creates a map backed by a concurrent cuckoo hash table.
+// Every key lives in one of two buckets, so a lookup compares at most 8
+// entries plus the keys sharing its hash beyond 4, and lookups take no lock.
+// It suits latency-sensitive readers; a write that finds both buckets full
+// moves entries along a short cuckoo path while holding every lock, or
+// doubles the table. To use the same hashing inside the partitions of a
+// concurrentMap, pass CuckooBackend to CreateConcurrentSliceMapWithBackend.
+func CreateCuckooMap(capacity int) *cuckooMap {
+	n := 8
+	for n*cuckooBucketSize < capacity {
+		n *= 2
+	}
+	return &cuckooMap{table: unsafe.Pointer(newCuckooTable(n))}
+}
+
+func newCuckooTable(n int) *cuckooTable {
+	stripes := cuckooStripes
+	if n < stripes {
+		stripes = n
+	}
+	return &cuckooTable{
+		buckets:  make([]cuckooBucket, n),
+		overflow: make([]unsafe.Pointer, n),
+		stripes:  make([]cuckooStripe, stripes),
+	}
+}
+
+func (m *cuckooMap) load() *cuckooTable {
+	return (*cuckooTable)(atomic.LoadPointer(&m.table))
+}
+
+// bucketPair 两个 hash 函数选出的桶
+func (t *cuckooTable) bucketPair(keyIndex uint64) (int, int) {
+	return cuckooBuckets(keyIndex, len(t.buckets))
+}
+
+// alternate 返回 keyIndex 在 b 之外的另一个桶
+func (t *cuckooTable) alternate(keyIndex uint64, b int) int {
+	b1, b2 := t.bucketPair(keyIndex)
+	if b == b1 {
+		return b2
+	}
+	return b1
+}
+
+func (t *cuckooTable) stripe(b int) *cuckooStripe {
+	return &t.stripes[b%len(t.stripes)]
+}
+
+// find 在两个桶和 b1 的溢出链表中查找 key，key 在溢出链表中时返回的位置为 nil，
+// 读操作不加锁时由调用方检查版本号
+func (t *cuckooTable) find(b1, b2 int, keyIndex uint64, equal func(stored any) bool) (*cuckooCell, *innerSlice) {
+	for _, b := range [2]int{b1, b2} {
+		bucket := &t.buckets[b]
+		for i := range bucket {
+			c := &bucket[i]
+			data := (*innerSlice)(atomic.LoadPointer(&c.data))
+			if data != nil && atomic.LoadUint64(&c.keyIndex) == keyIndex && equal(data.key) {
+				return c, data
+			}
+		}
+	}
+	return nil, findNode((*viewNode)(atomic.LoadPointer(&t.overflow[b1])), keyIndex, equal)
+}
+
+// replaceOverflow 替换或删除 b 的溢出链表中的 key，data 为 nil 时删除，返回 key 是否存在，
+// 调用方持有 b 的锁
+func (t *cuckooTable) replaceOverflow(b int, keyIndex uint64, equal func(stored any) bool, data *innerSlice) bool {
+	head, found := replaceNode((*viewNode)(atomic.LoadPointer(&t.overflow[b])), keyIndex, equal, data)
+	atomic.StorePointer(&t.overflow[b], unsafe.Pointer(head))
+	return found
+}
+
+func (t *cuckooTable) freeCell(b int) *cuckooCell {
+	bucket := &t.buckets[b]
+	for i := range bucket {
+		if bucket[i].data == nil {
+			return &bucket[i]
+		}
+	}
+	return nil
+}
+
+// lockPair 按分段顺序给两个桶加锁，表已被替换时返回 false
+func (t *cuckooTable) lockPair(b1, b2 int) bool {
+	s1, s2 := b1%len(t.stripes), b2%len(t.stripes)
+	if s1 > s2 {
+		s1, s2 = s2, s1
+	}
+	t.stripes[s1].mu.Lock()
+	if s2 != s1 {
+		t.stripes[s2].mu.Lock()
+	}
+	if atomic.LoadInt32(&t.moved) == 1 {
+		t.unlockPair(b1, b2)
+		return false
+	}
+	return true
+}
+
+func (t *cuckooTable) unlockPair(b1, b2 int) {
+	s1, s2 := t.stripe(b1), t.stripe(b2)
+	s1.mu.Unlock()
+	if s2 != s1 {
+		s2.mu.Unlock()
+	}
+}
+
+// beginWrite 把版本号改为奇数，此后不加锁的读会重试
+func (t *cuckooTable) beginWrite(b1, b2 int) {
+	s1, s2 := t.stripe(b1), t.stripe(b2)
+	atomic.AddUint64(&s1.seq, 1)
+	if s2 != s1 {
+		atomic.AddUint64(&s2.seq, 1)
+	}
+}
+
+// endWrite 把版本号改回偶数
+func (t *cuckooTable) endWrite(b1, b2 int) {
+	t.beginWrite(b1, b2)
+}
+
+func (t *cuckooTable) lockAll() bool {
+	for i := range t.stripes {
+		t.stripes[i].mu.Lock()
+	}
+	if atomic.LoadInt32(&t.moved) == 1 {
+		t.unlockAll()
+		return false
+	}
+	return true
+}
+
+func (t *cuckooTable) unlockAll() {
+	for i := range t.stripes {
+		t.stripes[i].mu.Unlock()
+	}
+}
+
+func (m *cuckooMap) Get(key Partitionable) (any, bool) {
+	return m.getFunc(key.PartitionKey(), func(stored any) bool {
+		return keyEqual(key, stored)
+	})
+}
+
+// GetString is Get(StrKey(key)) without allocating a key.
+func (m *cuckooMap) GetString(key string) (any, bool) {
+	return m.getFunc(hash(key), func(stored any) bool {
+		s, ok := stored.(string)
+		return ok && s == key
+	})
+}
+
+// GetInt64 is Get(I64Key(key)) without allocating a key.
+func (m *cuckooMap) GetInt64(key int64) (any, bool) {
+	keyIndex := uint64(key)
+	return m.getFunc(keyIndex, func(stored any) bool {
+		u, ok := stored.(uint64)
+		return ok && u == keyIndex
+	})
+}
+
+func (m *cuckooMap) getFunc(keyIndex uint64, equal func(stored any) bool) (any, bool) {
+	for {
+		t := m.load()
+		b1, b2 := t.bucketPair(keyIndex)
+		s1, s2 := t.stripe(b1), t.stripe(b2)
+		v1, v2 := atomic.LoadUint64(&s1.seq), atomic.LoadUint64(&s2.seq)
+		if v1&1 == 1 || v2&1 == 1 {
+			runtime.Gosched()
+			continue
+		}
+
+		_, data := t.find(b1, b2, keyIndex, equal)
+		if atomic.LoadUint64(&s1.seq) != v1 || atomic.LoadUint64(&s2.seq) != v2 || atomic.LoadInt32(&t.moved) == 1 {
+			continue
+		}
+		if data == nil {
+			return nil, false
+		}
+		return *(*any)(data.Value), true
+	}
+}
+
+func (m *cuckooMap) Set(key Partitionable, v any) {
+	keyIndex := key.PartitionKey()
+	data := &innerSlice{key: key.Value(), Value: unsafe.Pointer(&v)}
+	equal := func(stored any) bool {
+		return keyEqual(key, stored)
+	}
+
+	for {
+		t := m.load()
+		b1, b2 := t.bucketPair(keyIndex)
+		if !t.lockPair(b1, b2) {
+			continue
+		}
+		c, old := t.find(b1, b2, keyIndex, equal)
+		if c == nil && old != nil {
+			t.beginWrite(b1, b2)
+			t.replaceOverflow(b1, keyIndex, equal, data)
+			t.endWrite(b1, b2)
+			t.unlockPair(b1, b2)
+			return
+		}
+		if c == nil {
+			if c = t.freeCell(b1); c == nil {
+				c = t.freeCell(b2)
+			}
+			if c == nil {
+				// 两个桶都满了，需要移动其它 key
+				t.unlockPair(b1, b2)
+				if m.insertSlow(t, keyIndex, data, equal) {
+					return
+				}
+				continue
+			}
+			atomic.AddInt64(&m.count, 1)
+		}
+		t.beginWrite(b1, b2)
+		atomic.StoreUint64(&c.keyIndex, keyIndex)
+		atomic.StorePointer(&c.data, unsafe.Pointer(data))
+		t.endWrite(b1, b2)
+		t.unlockPair(b1, b2)
+		return
+	}
+}
+
+// insertSlow 持有所有锁，沿 cuckoo 路径腾出位置，找不到路径时扩容。
+// t 已被替换时返回 false，由调用方重试
+func (m *cuckooMap) insertSlow(t *cuckooTable, keyIndex uint64, data *innerSlice, equal func(stored any) bool) bool {
+	if !t.lockAll() {
+		return false
+	}
+	defer t.unlockAll()
+
+	for i := range t.stripes {
+		atomic.AddUint64(&t.stripes[i].seq, 1)
+	}
+	defer func() {
+		for i := range t.stripes {
+			atomic.AddUint64(&t.stripes[i].seq, 1)
+		}
+	}()
+
+	b1, b2 := t.bucketPair(keyIndex)
+	if c, old := t.find(b1, b2, keyIndex, equal); c != nil {
+		atomic.StorePointer(&c.data, unsafe.Pointer(data))
+		return true
+	} else if old != nil {
+		t.replaceOverflow(b1, keyIndex, equal, data)
+		return true
+	}
+	atomic.AddInt64(&m.count, 1)
+	if t.placeLocked(keyIndex, data) {
+		return true
+	}
+
+	// 扩容后在新表中插入，新表发布之前没有其它读写
+	n := len(t.buckets) * 2
+	for {
+		nt := newCuckooTable(n)
+		if nt.copyFrom(t) && nt.placeLocked(keyIndex, data) {
+			atomic.StorePointer(&m.table, unsafe.Pointer(nt))
+			atomic.StoreInt32(&t.moved, 1)
+			return true
+		}
+		n *= 2
+	}
+}
+
+func (t *cuckooTable) copyFrom(old *cuckooTable) bool {
+	for b := range old.buckets {
+		for _, c := range old.buckets[b] {
+			if c.data != nil && !t.placeLocked(c.keyIndex, (*innerSlice)(c.data)) {
+				return false
+			}
+		}
+		for x := (*viewNode)(old.overflow[b]); x != nil; x = x.next {
+			if !t.placeLocked(x.keyIndex, x.data) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// placeLocked 插入不存在的 key。找不到 cuckoo 路径，并且两个桶中 hash 相同的 key 已经
+// 占满一个桶时，扩容也不能腾出位置，放入第一个桶的溢出链表。调用方持有所有锁
+func (t *cuckooTable) placeLocked(keyIndex uint64, data *innerSlice) bool {
+	if t.insertLocked(keyIndex, data) {
+		return true
+	}
+
+	b1, b2 := t.bucketPair(keyIndex)
+	same := 0
+	for _, b := range [2]int{b1, b2} {
+		for _, c := range t.buckets[b] {
+			if c.data != nil && c.keyIndex == keyIndex {
+				same++
+			}
+		}
+		if b1 == b2 {
+			break
+		}
+	}
+	if same < cuckooBucketSize {
+		return false
+	}
+	head := (*viewNode)(t.overflow[b1])
+	atomic.StorePointer(&t.overflow[b1], unsafe.Pointer(&viewNode{keyIndex: keyIndex, data: data, next: head}))
+	return true
+}
+
+// cuckooNode 广度优先搜索中的一个桶，slot 为父节点的桶中要移到这个桶的位置
+type cuckooNode struct {
+	bucket int
+	parent int
+	slot   int
+	depth  int
+}
+
+// insertLocked 插入不存在的 key，需要时沿 cuckoo 路径移动其它 key，调用方持有所有锁
+func (t *cuckooTable) insertLocked(keyIndex uint64, data *innerSlice) bool {
+	b1, b2 := t.bucketPair(keyIndex)
+	nodes := []cuckooNode{{bucket: b1, parent: -1}, {bucket: b2, parent: -1}}
+	for i := 0; i < len(nodes) && len(nodes) < cuckooMaxSearch; i++ {
+		node := nodes[i]
+		if c := t.freeCell(node.bucket); c != nil {
+			if c = t.movePath(nodes, i, c); c == nil {
+				return false
+			}
+			atomic.StoreUint64(&c.keyIndex, keyIndex)
+			atomic.StorePointer(&c.data, unsafe.Pointer(data))
+			return true
+		}
+		if node.depth >= cuckooMaxPath {
+			continue
+		}
+		for slot, c := range t.buckets[node.bucket] {
+			nodes = append(nodes, cuckooNode{
+				bucket: t.alternate(c.keyIndex, node.bucket),
+				parent: i,
+				slot:   slot,
+				depth:  node.depth + 1,
+			})
+		}
+	}
+	return false
+}
+
+// movePath 从路径末尾开始把每个 key 移到它的另一个桶，返回根节点的桶中腾出的位置。
+// 同一个桶在路径中出现两次时路径可能失效，此时返回 nil，已经移动的 key 仍然有效
+func (t *cuckooTable) movePath(nodes []cuckooNode, i int, free *cuckooCell) *cuckooCell {
+	for node := nodes[i]; node.parent >= 0; node = nodes[node.parent] {
+		src := &t.buckets[nodes[node.parent].bucket][node.slot]
+		if src.data == nil || free.data != nil || t.alternate(src.keyIndex, nodes[node.parent].bucket) != node.bucket {
+			return nil
+		}
+		atomic.StoreUint64(&free.keyIndex, src.keyIndex)
+		atomic.StorePointer(&free.data, src.data)
+		atomic.StorePointer(&src.data, nil)
+		free = src
+	}
+	return free
+}
+
+func (m *cuckooMap) Delete(key Partitionable) {
+	keyIndex := key.PartitionKey()
+	equal := func(stored any) bool {
+		return keyEqual(key, stored)
+	}
+
+	for {
+		t := m.load()
+		b1, b2 := t.bucketPair(keyIndex)
+		if !t.lockPair(b1, b2) {
+			continue
+		}
+		if c, old := t.find(b1, b2, keyIndex, equal); c != nil {
+			t.beginWrite(b1, b2)
+			atomic.StorePointer(&c.data, nil)
+			t.endWrite(b1, b2)
+			atomic.AddInt64(&m.count, -1)
+		} else if old != nil {
+			t.beginWrite(b1, b2)
+			t.replaceOverflow(b1, keyIndex, equal, nil)
+			t.endWrite(b1, b2)
+			atomic.AddInt64(&m.count, -1)
+		}
+		t.unlockPair(b1, b2)
+		return
+	}
+}
+
+func (m *cuckooMap) Len() int {
+	return int(atomic.LoadInt64(&m.count))
+}
+
+// Range 持有所有锁复制一份数据后再调用 f，f 中可以读写 map
+func (m *cuckooMap) Range(f func(key, value any) bool) {
+	var all []*innerSlice
+	for {
+		t := m.load()
+		if !t.lockAll() {
+			continue
+		}
+		all = make([]*innerSlice, 0, m.Len())
+		for b := range t.buckets {
+			for _, c := range t.buckets[b] {
+				if c.data != nil {
+					all = append(all, (*innerSlice)(c.data))
+				}
+			}
+			for x := (*viewNode)(t.overflow[b]); x != nil; x = x.next {
+				all = append(all, x.data)
+			}
+		}
+		t.unlockAll()
+		break
+	}
+
+	for _, data := range all {
+		if !f(data.key, *(*any)(data.Value)) {
+			return
+		}
+	}
+}
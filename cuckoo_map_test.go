@@ -0,0 +1,141 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCuckooMap(t *testing.T) {
+	num := 100000
+	var mapData Map = CreateCuckooMap(16)
+	for i := 0; i < num; i++ {
+		mapData.Set(I64Key(int64(i)), i)
+	}
+	for i := 0; i < num; i += 3 {
+		mapData.Delete(I64Key(int64(i)))
+	}
+	mapData.Set(StrKey("a"), "a")
+	mapData.Set(StrKey("a"), "b")
+
+	for i := 0; i < num; i++ {
+		v, ok := mapData.GetInt64(int64(i))
+		if i%3 == 0 && ok || i%3 != 0 && (!ok || v.(int) != i) {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if v, ok := mapData.GetString("a"); !ok || v.(string) != "b" {
+		t.Errorf("get a --> %v, %v", v, ok)
+	}
+
+	want := num - (num+2)/3 + 1
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if mapData.Len() != want || n != want {
+		t.Errorf("len --> %v, range --> %v, want %v", mapData.Len(), n, want)
+	}
+}
+
+func TestCuckooMapGoroutine(t *testing.T) {
+	mapData := CreateCuckooMap(0)
+	goroutineNum := 8
+	num := 5000
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < goroutineNum; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				key := strconv.Itoa(g) + ":" + strconv.Itoa(i)
+				mapData.Set(StrKey(key), i)
+				if i%2 == 0 {
+					mapData.Delete(StrKey(key))
+				}
+			}
+		}(g)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				// 读到的值必须是写入的值
+				if v, ok := mapData.GetString(strconv.Itoa(g) + ":" + strconv.Itoa(i)); ok && v.(int) != i {
+					t.Errorf("get %v --> %v", i, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if mapData.Len() != goroutineNum*num/2 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+	for g := 0; g < goroutineNum; g++ {
+		for i := 1; i < num; i += 2 {
+			if v, ok := mapData.GetString(strconv.Itoa(g) + ":" + strconv.Itoa(i)); !ok || v.(int) != i {
+				t.Fatalf("get %v:%v --> %v, %v", g, i, v, ok)
+			}
+		}
+	}
+}
+
+func TestCuckooMapCollide(t *testing.T) {
+	mapData := CreateCuckooMap(0)
+	num := 100
+	for i := 0; i < num; i++ {
+		mapData.Set(collideKey(strconv.Itoa(i)), i)
+	}
+	// 其它 key 使表扩容，溢出链表中的 key 要复制到新表
+	for i := 0; i < 1000; i++ {
+		mapData.Set(I64Key(int64(i)), i)
+	}
+	for i := 0; i < num; i += 2 {
+		mapData.Delete(collideKey(strconv.Itoa(i)))
+	}
+	mapData.Set(collideKey("99"), 990)
+
+	for i := 0; i < num; i++ {
+		v, ok := mapData.Get(collideKey(strconv.Itoa(i)))
+		switch {
+		case i%2 == 0:
+			if ok {
+				t.Fatalf("deleted %v found", i)
+			}
+		case i == 99:
+			if !ok || v.(int) != 990 {
+				t.Errorf("get 99 --> %v, %v", v, ok)
+			}
+		case !ok || v.(int) != i:
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if want := num/2 + 1000; mapData.Len() != want || n != want {
+		t.Errorf("len --> %v, range --> %v, want %v", mapData.Len(), n, want)
+	}
+}
+
+func BenchmarkCuckooMapGet(b *testing.B) {
+	num := 100000
+	mapData := CreateCuckooMap(num)
+	for i := 0; i < num; i++ {
+		mapData.Set(I64Key(int64(i)), i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			mapData.GetInt64(int64(i % num))
+			i++
+		}
+	})
+}
@@ -0,0 +1,637 @@
+package HighPerformanceMap
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// DiskTierConfig 磁盘层的参数，零值使用默认值
+type DiskTierConfig struct {
+	SegmentSize     int64                           // 段文件写满该大小后写入新的段，默认 64MB
+	CompactInterval time.Duration                   // 后台压缩的周期，默认 1min
+	CompactRatio    float64                         // 有效数据少于段大小的该比例时压缩，默认 0.5
+	Marshal         func(value any) ([]byte, error) // 默认 encoding/gob，保留值的动态类型；RegisterJSONType 之后为 encoding/json
+	Unmarshal       func(data []byte) (any, error)  // 与 Marshal 对应，两者需要同时设置
+}
+
+// ErrIncompleteCodec is returned by EnableDiskTier when only one of Marshal
+// and Unmarshal is set.
+var ErrIncompleteCodec = errors.New("HighPerformanceMap: disk tier needs both Marshal and Unmarshal")
+
+// gobMarshal 编码值和它的动态类型，类型没有注册时注册后重试。段文件只在本进程中读取，
+// 注册在解码时同样有效
+func gobMarshal(value any) ([]byte, error) {
+	b, err := gobEncode(value)
+	if err != nil && value != nil {
+		if err = gobRegister(value); err == nil {
+			b, err = gobEncode(value)
+		}
+	}
+	return b, err
+}
+
+func gobEncode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&value)
+	return buf.Bytes(), err
+}
+
+// gobRegister 不同的类型使用相同的名字时 gob.Register 会 panic
+func gobRegister(value any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("HighPerformanceMap: cannot register %T with gob: %v", value, r)
+		}
+	}()
+	gob.Register(value)
+	return nil
+}
+
+func gobUnmarshal(data []byte) (any, error) {
+	var v any
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// diskTier 保存因超过 MaxBytes 被移出内存的值。值追加写入段文件，索引只在内存中，
+// 同一个 key 只会在内存或磁盘中的一处。spill、take、remove 由调用方持有 key 所在分区的写锁
+type diskTier struct {
+	dir string
+	cfg DiskTierConfig
+	now func() int64 // map 的时钟
+
+	mu       sync.Mutex
+	index    map[uint64][]diskEntry // keyIndex → hash 相同的 key
+	count    int                    // 未过期的 key 的数量
+	expiring expiryHeap             // 带过期时间的 key，key 被删除后仍留在堆中，出堆时跳过
+	segments []*diskSegment         // 按创建顺序，最后一个是正在写入的段
+	nextID   int
+	closed   bool // CloseDiskTier 之后不再写入，被淘汰的值直接丢弃
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type diskSegment struct {
+	id   int
+	f    *os.File
+	size int64 // 已写入的字节数
+	live int64 // 仍被索引引用的字节数
+}
+
+// diskEntry 磁盘上一个值的位置
+type diskEntry struct {
+	key      any
+	segment  *diskSegment
+	offset   int64
+	length   int64
+	expireAt int64 // SetWithTTL 写入的值的过期时间，0 表示不过期
+	expired  bool  // 已过期，不再计入 count，等待 DeleteExpired 或压缩删除
+}
+
+// expiryRef 按过期时间排序的磁盘上的 key
+type expiryRef struct {
+	keyIndex uint64
+	key      any
+	expireAt int64
+}
+
+type expiryHeap []expiryRef
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryRef)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// EnableDiskTier spills the entries evicted by SetMaxBytes to append-only
+// segment files in dir instead of dropping them. Get moves a spilled entry
+// back to memory, Len and Range include spilled entries, and segments mostly
+// holding overwritten data are compacted in the background. Expired spilled
+// entries are not counted by Len and are removed by DeleteExpired and before
+// each compaction.
+//
+// By default values are encoded with encoding/gob together with their
+// dynamic type, so a promoted value has the type it was Set with; types are
+// registered with gob.Register the first time they are spilled. After
+// RegisterJSONType, values are encoded as JSON and decoded into the
+// registered type. Values that cannot be encoded are evicted instead of
+// spilled; pass Marshal and Unmarshal for them. It must be called before
+// the map is used, and CloseDiskTier must be called to stop the compaction
+// and remove the segment files.
+func (m *concurrentMap) EnableDiskTier(dir string, cfg DiskTierConfig) error {
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 64 << 20
+	}
+	if cfg.CompactInterval <= 0 {
+		cfg.CompactInterval = time.Minute
+	}
+	if cfg.CompactRatio <= 0 {
+		cfg.CompactRatio = 0.5
+	}
+	if cfg.Marshal == nil && cfg.Unmarshal == nil {
+		if valueType := m.jsonValueType; valueType != nil {
+			cfg.Marshal = json.Marshal
+			cfg.Unmarshal = func(data []byte) (any, error) {
+				ptr := reflect.New(valueType)
+				err := json.Unmarshal(data, ptr.Interface())
+				return ptr.Elem().Interface(), err
+			}
+		} else {
+			cfg.Marshal, cfg.Unmarshal = gobMarshal, gobUnmarshal
+		}
+	}
+	if cfg.Marshal == nil || cfg.Unmarshal == nil {
+		return ErrIncompleteCodec
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	d := &diskTier{
+		dir:   dir,
+		cfg:   cfg,
+		now:   m.now,
+		index: make(map[uint64][]diskEntry),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := d.rotate(); err != nil {
+		return err
+	}
+	m.disk = d
+
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(cfg.CompactInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				m.CompactDisk()
+			}
+		}
+	}()
+	return nil
+}
+
+// CompactDisk removes the expired spilled entries, then rewrites the
+// segments whose live data is below CompactRatio and removes them.
+func (m *concurrentMap) CompactDisk() error {
+	if m.disk == nil {
+		return nil
+	}
+	m.deleteExpiredSpilled()
+	return m.disk.compact()
+}
+
+// deleteExpiredSpilled 删除磁盘上已过期的 key 和它们在附加索引中的占位值，返回删除的数量
+func (m *concurrentMap) deleteExpiredSpilled() int {
+	d := m.disk
+	now := d.now()
+	removed := 0
+	for _, k := range d.expiredKeys(now) {
+		m.mu.RLock()
+		p := m.lockPartition(k.keyIndex, true)
+		active := m.watchers.active()
+		value, ok := d.removeExpired(k.keyIndex, k.equal, now, active)
+		if ok {
+			for _, idx := range m.indexes {
+				idx.delete(k.key)
+			}
+			if active {
+				m.watchers.enqueue(k.keyIndex, Event{Type: EventExpire, Key: k.key, OldValue: value})
+			}
+			removed++
+		}
+		p.mu.Unlock()
+		m.mu.RUnlock()
+	}
+	m.watchers.deliver()
+	return removed
+}
+
+// CloseDiskTier stops the compaction, drops the spilled entries and removes
+// the segment files. Entries evicted afterwards are dropped.
+func (m *concurrentMap) CloseDiskTier() error {
+	d := m.disk
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+	close(d.stop)
+	<-d.done
+
+	// 丢弃的 key 从附加索引中删除，按 key 加分区的写锁，与移回内存和删除互斥
+	var refs []setKey
+	d.mu.Lock()
+	for keyIndex, entries := range d.index {
+		for _, e := range entries {
+			refs = append(refs, setKey{keyIndex, e.key})
+		}
+	}
+	d.mu.Unlock()
+	for _, r := range refs {
+		m.mu.RLock()
+		p := m.lockPartition(r.keyIndex, true)
+		d.mu.Lock()
+		_, ok := d.unindex(r.keyIndex, r.equal)
+		d.mu.Unlock()
+		if ok {
+			for _, idx := range m.indexes {
+				idx.delete(r.key)
+			}
+		}
+		p.mu.Unlock()
+		m.mu.RUnlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	for _, s := range d.segments {
+		if e := d.removeSegment(s); e != nil && err == nil {
+			err = e
+		}
+	}
+	d.segments, d.index, d.count, d.expiring = nil, make(map[uint64][]diskEntry), 0, nil
+	return err
+}
+
+// spilledKey 附加索引中代表写入磁盘的 key 的值，读取时到内存或磁盘中查找
+type spilledKey struct {
+	keyIndex uint64
+}
+
+// spilledStub 返回写入磁盘的 key 在附加索引中的占位值
+func spilledStub(keyIndex uint64, key any) *innerSlice {
+	var stored any = &spilledKey{keyIndex}
+	return &innerSlice{key: key, Value: unsafe.Pointer(&stored)}
+}
+
+// indexedValue 返回从附加索引中复制出的 data 的值，data 已过期或者 key 已被删除时返回 false。
+// data 为占位值时加分区的读锁，此时 key 只会在内存或磁盘中的一处
+func (m *concurrentMap) indexedValue(data *innerSlice) (any, bool) {
+	s, ok := (*(*any)(data.Value)).(*spilledKey)
+	if !ok {
+		return m.getValue(data.Value), !m.expired(data)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(s.keyIndex, false)
+	defer p.mu.RUnlock()
+	equal := func(stored any) bool {
+		return stored == data.key
+	}
+	// 复制之后 key 可能已经被移回内存
+	if index, _, ok := p.lookupFunc(s.keyIndex, equal); ok {
+		data = p.innerSlice[index]
+		return m.getValue(data.Value), !m.expired(data)
+	}
+	return m.disk.peek(s.keyIndex, equal, m.now())
+}
+
+// takeSpilled 删除磁盘上的 key 和它在附加索引中的占位值，返回它的值，调用方持有分区的写锁
+func (m *concurrentMap) takeSpilled(keyIndex uint64, equal func(stored any) bool) *innerSlice {
+	data := m.disk.take(keyIndex, equal)
+	if data != nil {
+		for _, idx := range m.indexes {
+			idx.delete(data.key)
+		}
+	}
+	return data
+}
+
+// promote 把磁盘上的 key 移回内存，调用方未持有锁
+func (m *concurrentMap) promote(keyIndex uint64, equal func(stored any) bool) (any, bool) {
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, true)
+	var data *innerSlice
+	// 加锁之前 key 可能已经被写入内存
+	if index, _, ok := p.lookupFunc(keyIndex, equal); ok {
+		data = p.innerSlice[index]
+	} else if data = m.disk.take(keyIndex, equal); data != nil {
+		p.insert(keyIndex, data)
+		m.setLocked(p, keyIndex, data, nil)
+		atomic.AddInt64(&m.count, 1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.rehash()
+	m.evict()
+
+	return m.liveValue(keyIndex, data)
+}
+
+func (d *diskTier) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.countExpired(d.now())
+	return d.count
+}
+
+// countExpired 把到期的 key 标记为已过期并从 count 中减去，调用方持有 d.mu。
+// 堆中的 key 已被删除、移回内存后再次写入或已被标记的跳过
+func (d *diskTier) countExpired(now int64) {
+	for len(d.expiring) > 0 && d.expiring[0].expireAt <= now {
+		r := heap.Pop(&d.expiring).(expiryRef)
+		entries := d.index[r.keyIndex]
+		for i := range entries {
+			if e := &entries[i]; e.key == r.key && e.expireAt == r.expireAt && !e.expired {
+				e.expired = true
+				d.count--
+				break
+			}
+		}
+	}
+}
+
+// expiredKeys 返回磁盘上已过期的 key
+func (d *diskTier) expiredKeys(now int64) []setKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var keys []setKey
+	for keyIndex, entries := range d.index {
+		for _, e := range entries {
+			if e.expireAt != 0 && e.expireAt <= now {
+				keys = append(keys, setKey{keyIndex, e.key})
+			}
+		}
+	}
+	return keys
+}
+
+// removeExpired 删除磁盘上已过期的 key，read 为 true 时返回它的值，key 不存在或未过期时返回 false。
+// 调用方持有分区的写锁
+func (d *diskTier) removeExpired(keyIndex uint64, equal func(stored any) bool, now int64, read bool) (any, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.index[keyIndex] {
+		if !equal(e.key) {
+			continue
+		}
+		if e.expireAt == 0 || e.expireAt > now {
+			return nil, false
+		}
+		var value any
+		if read {
+			value, _ = d.read(e)
+		}
+		d.unindex(keyIndex, equal)
+		return value, true
+	}
+	return nil, false
+}
+
+// rotate 创建新的段文件用于写入，调用方持有 d.mu
+func (d *diskTier) rotate() error {
+	path := filepath.Join(d.dir, fmt.Sprintf("%06d.seg", d.nextID))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	d.segments = append(d.segments, &diskSegment{id: d.nextID, f: f})
+	d.nextID++
+	return nil
+}
+
+func (d *diskTier) removeSegment(s *diskSegment) error {
+	name := s.f.Name()
+	err := s.f.Close()
+	if e := os.Remove(name); err == nil {
+		err = e
+	}
+	return err
+}
+
+// write 把 data 追加到正在写入的段，调用方持有 d.mu
+func (d *diskTier) write(data []byte) (*diskSegment, int64, error) {
+	s := d.segments[len(d.segments)-1]
+	if s.size > 0 && s.size+int64(len(data)) > d.cfg.SegmentSize {
+		if err := d.rotate(); err != nil {
+			return nil, 0, err
+		}
+		s = d.segments[len(d.segments)-1]
+	}
+	offset := s.size
+	if _, err := s.f.WriteAt(data, offset); err != nil {
+		return nil, 0, err
+	}
+	s.size += int64(len(data))
+	s.live += int64(len(data))
+	return s, offset, nil
+}
+
+// spill 把从内存中删除的 data 写入磁盘
+func (d *diskTier) spill(keyIndex uint64, data *innerSlice) error {
+	value, expireAt := *(*any)(data.Value), int64(0)
+	if t, ok := value.(*ttlValue); ok {
+		value, expireAt = t.value, t.expireAt
+	}
+	b, err := d.cfg.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return os.ErrClosed
+	}
+	s, offset, err := d.write(b)
+	if err != nil {
+		return err
+	}
+	d.index[keyIndex] = append(d.index[keyIndex], diskEntry{
+		key:      data.key,
+		segment:  s,
+		offset:   offset,
+		length:   int64(len(b)),
+		expireAt: expireAt,
+	})
+	d.count++
+	if expireAt != 0 {
+		heap.Push(&d.expiring, expiryRef{keyIndex, data.key, expireAt})
+	}
+	return nil
+}
+
+// unindex 从索引中删除 key 并返回它的位置，调用方持有 d.mu
+func (d *diskTier) unindex(keyIndex uint64, equal func(stored any) bool) (diskEntry, bool) {
+	entries := d.index[keyIndex]
+	for i, e := range entries {
+		if !equal(e.key) {
+			continue
+		}
+		if len(entries) == 1 {
+			delete(d.index, keyIndex)
+		} else {
+			d.index[keyIndex] = append(entries[:i:i], entries[i+1:]...)
+		}
+		if !e.expired {
+			d.count--
+		}
+		e.segment.live -= e.length
+		return e, true
+	}
+	return diskEntry{}, false
+}
+
+// remove 删除磁盘上的 key，不读取它的值
+func (d *diskTier) remove(keyIndex uint64, equal func(stored any) bool) {
+	d.mu.Lock()
+	d.unindex(keyIndex, equal)
+	d.mu.Unlock()
+}
+
+// take 删除磁盘上的 key 并返回它的值，key 不存在或读取失败时返回 nil
+func (d *diskTier) take(keyIndex uint64, equal func(stored any) bool) *innerSlice {
+	d.mu.Lock()
+	e, ok := d.unindex(keyIndex, equal)
+	var value any
+	var err error
+	if ok {
+		value, err = d.read(e)
+	}
+	d.mu.Unlock()
+	if !ok || err != nil {
+		return nil
+	}
+
+	var stored any = value
+	if e.expireAt != 0 {
+		stored = &ttlValue{value: value, expireAt: e.expireAt}
+	}
+	return &innerSlice{key: e.key, Value: unsafe.Pointer(&stored)}
+}
+
+// read 读取并解码 e 的值，调用方持有 d.mu
+func (d *diskTier) read(e diskEntry) (any, error) {
+	b := make([]byte, e.length)
+	if _, err := e.segment.f.ReadAt(b, e.offset); err != nil {
+		return nil, err
+	}
+	return d.cfg.Unmarshal(b)
+}
+
+// peek 读取磁盘上未过期的 key 的值，不移回内存
+func (d *diskTier) peek(keyIndex uint64, equal func(stored any) bool, now int64) (any, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.index[keyIndex] {
+		if equal(e.key) {
+			if e.expireAt != 0 && e.expireAt <= now {
+				return nil, false
+			}
+			value, err := d.read(e)
+			return value, err == nil
+		}
+	}
+	return nil, false
+}
+
+// rangeEntries 复制索引后依次读取未过期的值，读取时 key 已被删除或移回内存的跳过
+func (d *diskTier) rangeEntries(now int64, f func(key, value any) bool) {
+	d.mu.Lock()
+	type entryRef struct {
+		keyIndex uint64
+		key      any
+	}
+	refs := make([]entryRef, 0, d.count)
+	for keyIndex, entries := range d.index {
+		for _, e := range entries {
+			refs = append(refs, entryRef{keyIndex, e.key})
+		}
+	}
+	d.mu.Unlock()
+
+	for _, r := range refs {
+		value, ok := d.peek(r.keyIndex, func(stored any) bool {
+			return stored == r.key
+		}, now)
+		if ok && !f(r.key, value) {
+			return
+		}
+	}
+}
+
+// compact 把有效数据过少的段中的值复制到正在写入的段，然后删除这些段
+func (d *diskTier) compact() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+
+	var sealed []*diskSegment
+	for _, s := range d.segments[:len(d.segments)-1] {
+		if float64(s.live) < float64(s.size)*d.cfg.CompactRatio {
+			sealed = append(sealed, s)
+		}
+	}
+	if len(sealed) == 0 {
+		return nil
+	}
+
+	compacting := make(map[*diskSegment]bool, len(sealed))
+	for _, s := range sealed {
+		compacting[s] = true
+	}
+	for _, entries := range d.index {
+		for i := range entries {
+			e := &entries[i]
+			if !compacting[e.segment] {
+				continue
+			}
+			b := make([]byte, e.length)
+			if _, err := e.segment.f.ReadAt(b, e.offset); err != nil {
+				return err
+			}
+			s, offset, err := d.write(b)
+			if err != nil {
+				return err
+			}
+			e.segment.live -= e.length
+			e.segment, e.offset = s, offset
+		}
+	}
+
+	var err error
+	segments := d.segments[:0]
+	for _, s := range d.segments {
+		if compacting[s] {
+			if e := d.removeSegment(s); e != nil && err == nil {
+				err = e
+			}
+		} else {
+			segments = append(segments, s)
+		}
+	}
+	d.segments = segments
+	return err
+}
@@ -0,0 +1,368 @@
+package HighPerformanceMap
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func segmentFiles(t *testing.T, dir string) int {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestDiskTierSpillAndPromote(t *testing.T) {
+	dir := t.TempDir()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.RegisterJSONType(JSONStringKey, "")
+	if err := mapData.EnableDiskTier(dir, DiskTierConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.SetMaxBytes(1000, func(key, value any) int64 { return 100 })
+
+	for i := int64(0); i < 100; i++ {
+		mapData.Set(I64Key(i), "value")
+	}
+	if mapData.MemoryUsage() > 1000 {
+		t.Errorf("usage over the limit --> %v", mapData.MemoryUsage())
+	}
+	if mapData.Len() != 100 || mapData.disk.len() != 90 {
+		t.Fatalf("len --> %v, disk len --> %v, want 100 with 90", mapData.Len(), mapData.disk.len())
+	}
+
+	for i := int64(0); i < 100; i++ {
+		if v, ok := mapData.Get(I64Key(i)); !ok || v != "value" {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if mapData.Len() != 100 {
+		t.Errorf("len after promotion --> %v", mapData.Len())
+	}
+
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 100 {
+		t.Errorf("range --> %v, want 100", n)
+	}
+
+	// 覆盖和删除磁盘上的 key
+	var spilled uint64
+	for keyIndex := range mapData.disk.index {
+		spilled = keyIndex
+		break
+	}
+	mapData.Set(I64Key(int64(spilled)), "new")
+	if v, _ := mapData.Get(I64Key(int64(spilled))); v != "new" {
+		t.Errorf("get after overwrite --> %v", v)
+	}
+	for keyIndex := range mapData.disk.index {
+		spilled = keyIndex
+		break
+	}
+	mapData.Delete(I64Key(int64(spilled)))
+	if _, ok := mapData.Get(I64Key(int64(spilled))); ok {
+		t.Error("deleted spilled key found")
+	}
+	if mapData.Len() != 99 {
+		t.Errorf("len --> %v, want 99", mapData.Len())
+	}
+}
+
+func TestDiskTierTTL(t *testing.T) {
+	clock := newFakeClock()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.SetMaxBytes(100, func(key, value any) int64 { return 100 })
+
+	mapData.SetWithTTL(StrKey("Hello"), 1.0, time.Minute)
+	mapData.Set(StrKey("World"), 2.0)
+	if mapData.disk.len() != 1 {
+		t.Fatalf("disk len --> %v, want 1", mapData.disk.len())
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, ok := mapData.Get(StrKey("Hello")); ok {
+		t.Error("expired key promoted from disk")
+	}
+	if v, ok := mapData.Get(StrKey("World")); !ok || v != 2.0 {
+		t.Errorf("get World --> %v, %v", v, ok)
+	}
+}
+
+func TestDiskTierDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	dir := t.TempDir()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	if err := mapData.EnableDiskTier(dir, DiskTierConfig{SegmentSize: 1, CompactInterval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.EnablePrefixIndex()
+	mapData.SetMaxBytes(100, func(key, value any) int64 { return 100 })
+
+	mapData.SetWithTTL(StrKey("Hello"), 1.0, time.Minute)
+	mapData.Set(StrKey("World"), 2.0)
+	if mapData.disk.len() != 1 {
+		t.Fatalf("disk len --> %v", mapData.disk.len())
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n := mapData.Len(); n != 1 {
+		t.Errorf("len --> %v", n)
+	}
+	if n := mapData.DeleteExpired(); n != 1 {
+		t.Errorf("expired --> %v", n)
+	}
+	if n := mapData.Len(); n != 1 {
+		t.Errorf("len --> %v", n)
+	}
+	visited := 0
+	mapData.Range(func(key, value any) bool {
+		visited++
+		return true
+	})
+	if visited != 1 {
+		t.Errorf("range visited --> %v", visited)
+	}
+	if n := mapData.disk.len(); n != 0 || len(mapData.disk.index) != 0 {
+		t.Errorf("disk len --> %v, disk index --> %v", n, len(mapData.disk.index))
+	}
+	var prefixed []string
+	mapData.ScanPrefix("", func(key string, value any) bool {
+		prefixed = append(prefixed, key)
+		return true
+	})
+	if len(prefixed) != 1 || prefixed[0] != "World" {
+		t.Errorf("prefix keys --> %v", prefixed)
+	}
+
+	// 压缩时同样删除过期的值，不再复制到新的段
+	mapData.SetWithTTL(StrKey("Hello"), 1.0, time.Minute)
+	mapData.Set(StrKey("World"), 3.0)
+	mapData.Set(StrKey("Other"), 4.0)
+	clock.Advance(2 * time.Minute)
+	if err := mapData.CompactDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mapData.disk.peek(StrKey("Hello").PartitionKey(), func(stored any) bool { return stored == "Hello" }, 0); ok {
+		t.Error("expired key kept by compaction")
+	}
+	if n := mapData.Len(); n != 2 {
+		t.Errorf("len --> %v", n)
+	}
+}
+
+func TestDiskTierCompaction(t *testing.T) {
+	dir := t.TempDir()
+	mapData := CreateConcurrentSliceMap(7)
+	if err := mapData.EnableDiskTier(dir, DiskTierConfig{SegmentSize: 32, CompactInterval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	mapData.SetMaxBytes(1, func(key, value any) int64 { return 1 })
+
+	// 只有一个值留在内存中，其余都写入磁盘，每个段 32 字节
+	for i := int64(0); i < 200; i++ {
+		mapData.Set(I64Key(i), float64(i))
+	}
+	before := segmentFiles(t, dir)
+	if before < 10 {
+		t.Fatalf("segment files --> %v", before)
+	}
+
+	// 删除大部分 key 后压缩
+	for i := int64(0); i < 180; i++ {
+		mapData.Delete(I64Key(i))
+	}
+	if err := mapData.CompactDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if after := segmentFiles(t, dir); after >= before/2 {
+		t.Errorf("segment files after compaction --> %v, before --> %v", after, before)
+	}
+	for i := int64(180); i < 200; i++ {
+		if v, ok := mapData.Get(I64Key(i)); !ok || v != float64(i) {
+			t.Fatalf("get %v after compaction --> %v, %v", i, v, ok)
+		}
+	}
+
+	if err := mapData.CloseDiskTier(); err != nil {
+		t.Fatal(err)
+	}
+	if n := segmentFiles(t, dir); n != 0 {
+		t.Errorf("segment files after close --> %v", n)
+	}
+}
+
+func TestDiskTierConcurrent(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(7)
+	if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{SegmentSize: 1 << 10, CompactInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.SetMaxBytes(20, func(key, value any) int64 { return 1 })
+
+	var wg sync.WaitGroup
+	for g := int64(0); g < 8; g++ {
+		wg.Add(1)
+		go func(g int64) {
+			defer wg.Done()
+			// 每个 goroutine 只写自己的 key，值总是 key 本身
+			for i := int64(0); i < 500; i++ {
+				key := g*1000 + i%50
+				switch i % 3 {
+				case 0:
+					mapData.Set(I64Key(key), float64(key))
+				case 1:
+					if v, ok := mapData.Get(I64Key(key)); ok && v != float64(key) {
+						t.Errorf("get %v --> %v", key, v)
+					}
+				default:
+					mapData.Delete(I64Key(key))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestDiskTierKeepsTypes(t *testing.T) {
+	type record struct {
+		Name string
+		Size int
+	}
+	mapData := CreateConcurrentSliceMap(7)
+	if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.SetMaxBytes(1, func(key, value any) int64 { return 1 })
+
+	values := []any{7, int64(-7), uint8(7), "seven", []byte("seven"), record{"a", 7}, map[string]int{"a": 7}, nil}
+	for i, v := range values {
+		mapData.Set(I64Key(int64(i)), v)
+	}
+	if mapData.disk.len() != len(values)-1 {
+		t.Fatalf("disk len --> %v, want %v", mapData.disk.len(), len(values)-1)
+	}
+	for i, want := range values {
+		v, ok := mapData.Get(I64Key(int64(i)))
+		if !ok || !reflect.DeepEqual(v, want) {
+			t.Errorf("get %v --> %#v, %v, want %#v", i, v, ok, want)
+		}
+	}
+
+	if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{Marshal: json.Marshal}); err != ErrIncompleteCodec {
+		t.Errorf("enable with only Marshal --> %v", err)
+	}
+}
+
+func TestDiskTierPrefixIndex(t *testing.T) {
+	for _, index := range []bool{true, false} {
+		mapData := CreateConcurrentSliceMap(7)
+		if index {
+			mapData.EnablePrefixIndex()
+		}
+		if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		mapData.SetMaxBytes(3, func(key, value any) int64 { return 1 })
+		for i := 0; i < 10; i++ {
+			mapData.Set(StrKey("t:"+strconv.Itoa(i)), i)
+		}
+		if mapData.disk.len() != 7 {
+			t.Fatalf("disk len --> %v", mapData.disk.len())
+		}
+
+		// 写入磁盘的 key 也能按前缀读到，有前缀索引时按字典序
+		var keys []string
+		mapData.ScanPrefix("t:", func(key string, value any) bool {
+			if value != int(key[2]-'0') {
+				t.Errorf("%v --> %v", key, value)
+			}
+			keys = append(keys, key)
+			return true
+		})
+		if len(keys) != 10 || index && !sort.StringsAreSorted(keys) {
+			t.Errorf("scan prefix --> %v", keys)
+		}
+
+		if n := mapData.DeletePrefix("t:"); n != 10 || mapData.Len() != 0 {
+			t.Errorf("delete prefix --> %v, len --> %v", n, mapData.Len())
+		}
+		if _, ok := mapData.Get(StrKey("t:0")); ok {
+			t.Error("deleted key found")
+		}
+		if index && mapData.prefixIndex.len != 0 {
+			t.Errorf("index len --> %v", mapData.prefixIndex.len)
+		}
+
+		// 关闭磁盘层后丢弃的 key 不再留在索引中
+		for i := 0; i < 10; i++ {
+			mapData.Set(StrKey("t:"+strconv.Itoa(i)), i)
+		}
+		if err := mapData.CloseDiskTier(); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		mapData.ScanPrefix("t:", func(key string, value any) bool {
+			n++
+			return true
+		})
+		if n != 3 || index && mapData.prefixIndex.len != 3 {
+			t.Errorf("scan prefix after close --> %v", n)
+		}
+	}
+}
+
+func TestDiskTierOrderedMap(t *testing.T) {
+	mapData := CreateOrderedSliceMap(7)
+	if err := mapData.EnableDiskTier(t.TempDir(), DiskTierConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer mapData.CloseDiskTier()
+	mapData.SetMaxBytes(3, func(key, value any) int64 { return 1 })
+	for i := int64(0); i < 10; i++ {
+		mapData.Set(I64Key(i), i)
+	}
+
+	var keys []int64
+	mapData.Ascend(func(key int64, value any) bool {
+		if value != key {
+			t.Errorf("%v --> %v", key, value)
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 10 || keys[0] != 0 || keys[9] != 9 {
+		t.Errorf("ascend --> %v", keys)
+	}
+	if min, v, ok := mapData.Min(); !ok || min != 0 || v != int64(0) {
+		t.Errorf("min --> %v, %v", min, v)
+	}
+	// 遍历不会把 key 移回内存
+	if mapData.disk.len() != 7 {
+		t.Errorf("disk len --> %v", mapData.disk.len())
+	}
+
+	mapData.Delete(I64Key(0))
+	if min, _, _ := mapData.Min(); min != 1 || mapData.Len() != 9 {
+		t.Errorf("min --> %v, len --> %v", min, mapData.Len())
+	}
+}
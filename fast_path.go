@@ -0,0 +1,18 @@
+package HighPerformanceMap
+
+// GetString is Get(StrKey(key)) without allocating a key.
+func (m *concurrentMap) GetString(key string) (any, bool) {
+	return m.getFunc(hash(key), func(stored any) bool {
+		s, ok := stored.(string)
+		return ok && s == key
+	})
+}
+
+// GetInt64 is Get(I64Key(key)) without allocating a key.
+func (m *concurrentMap) GetInt64(key int64) (any, bool) {
+	keyIndex := uint64(key)
+	return m.getFunc(keyIndex, func(stored any) bool {
+		u, ok := stored.(uint64)
+		return ok && u == keyIndex
+	})
+}
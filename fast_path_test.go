@@ -0,0 +1,79 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGetFastPath(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.Set(StrKey("Hello"), 123)
+	mapData.Set(I64Key(-111), "jinjin")
+	mapData.Set(U64Key(7), 7)
+
+	if v, ok := mapData.GetString("Hello"); !ok || v.(int) != 123 {
+		t.Error("get string failed")
+	}
+	if v, ok := mapData.GetInt64(-111); !ok || v.(string) != "jinjin" {
+		t.Error("get int64 failed")
+	}
+	if _, ok := mapData.GetString("World"); ok {
+		t.Error("get missing string")
+	}
+	if _, ok := mapData.GetInt64(8); ok {
+		t.Error("get missing int64")
+	}
+}
+
+func TestGetFastPathAllocs(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	key := "tenant:42:user:7 with a key longer than thirty two bytes"
+	mapData.Set(StrKey(key), 1)
+	mapData.Set(I64Key(42), 2)
+
+	if n := testing.AllocsPerRun(100, func() {
+		if _, ok := mapData.GetString(key); !ok {
+			t.Fatal("get string failed")
+		}
+	}); n != 0 {
+		t.Errorf("GetString allocs --> %v", n)
+	}
+
+	if n := testing.AllocsPerRun(100, func() {
+		if _, ok := mapData.GetInt64(42); !ok {
+			t.Fatal("get int64 failed")
+		}
+	}); n != 0 {
+		t.Errorf("GetInt64 allocs --> %v", n)
+	}
+}
+
+func BenchmarkGetFastPath(b *testing.B) {
+	num := 100
+	mapData := CreateConcurrentSliceMap(99)
+	keys := make([]string, num)
+	for i := 0; i < num; i++ {
+		keys[i] = strconv.Itoa(i)
+		mapData.Set(StrKey(keys[i]), i)
+		mapData.Set(I64Key(int64(i)), i)
+	}
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mapData.Get(StrKey(keys[i%num]))
+		}
+	})
+	b.Run("GetString", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mapData.GetString(keys[i%num])
+		}
+	})
+	b.Run("GetInt64", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mapData.GetInt64(int64(i % num))
+		}
+	})
+}
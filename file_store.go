@@ -0,0 +1,165 @@
+package HighPerformanceMap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// fileStore 参考实现的 Store，每次写入在文件末尾追加一行 JSON 记录，打开时重放记录，
+// 所有值同时保存在内存中
+type fileStore struct {
+	mu   sync.Mutex
+	f    *os.File
+	data map[any]any // key.Value() → 值
+}
+
+var _ Store = (*fileStore)(nil)
+
+// fileRecord 文件中的一行，Kind 为 key 的类型：s 表示 string，u 表示 uint64
+type fileRecord struct {
+	Kind    string `json:"t"`
+	Key     string `json:"k"`
+	Value   any    `json:"v,omitempty"`
+	Deleted bool   `json:"d,omitempty"`
+}
+
+// OpenFileStore opens or creates a Store appending every write to the file at
+// path. Keys whose Value is a string or uint64 are supported, and values are
+// decoded the way encoding/json decodes into an interface{} when the file is
+// reopened.
+func OpenFileStore(path string) (*fileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileStore{f: f, data: make(map[any]any)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<26)
+	for scanner.Scan() {
+		var r fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("HighPerformanceMap: corrupt store file %s: %v", path, err)
+		}
+		key, err := r.key()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.apply(key, r.Value, r.Deleted)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newFileRecord(key any, value any, deleted bool) (fileRecord, error) {
+	r := fileRecord{Value: value, Deleted: deleted}
+	switch k := key.(type) {
+	case string:
+		r.Kind, r.Key = "s", k
+	case uint64:
+		r.Kind, r.Key = "u", strconv.FormatUint(k, 10)
+	default:
+		return r, fmt.Errorf("HighPerformanceMap: unsupported store key type %T", key)
+	}
+	if deleted {
+		r.Value = nil
+	}
+	return r, nil
+}
+
+func (r fileRecord) key() (any, error) {
+	switch r.Kind {
+	case "s":
+		return r.Key, nil
+	case "u":
+		return strconv.ParseUint(r.Key, 10, 64)
+	}
+	return nil, fmt.Errorf("HighPerformanceMap: unknown store key kind %q", r.Kind)
+}
+
+func (s *fileStore) apply(key, value any, deleted bool) {
+	if deleted {
+		delete(s.data, key)
+	} else {
+		s.data[key] = value
+	}
+}
+
+// append 编码所有记录后一次写入文件，编码失败时不写入任何记录
+func (s *fileStore) append(entries []StoreEntry) error {
+	var buf []byte
+	for _, e := range entries {
+		r, err := newFileRecord(e.Key.Value(), e.Value, e.Deleted)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrStoreClosed
+	}
+	if _, err := s.f.Write(buf); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s.apply(e.Key.Value(), e.Value, e.Deleted)
+	}
+	return nil
+}
+
+func (s *fileStore) Load(key Partitionable) (any, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key.Value()]
+	return v, ok, nil
+}
+
+func (s *fileStore) Save(key Partitionable, value any) error {
+	return s.append([]StoreEntry{{Key: key, Value: value}})
+}
+
+func (s *fileStore) Delete(key Partitionable) error {
+	return s.append([]StoreEntry{{Key: key, Deleted: true}})
+}
+
+func (s *fileStore) SaveBatch(entries []StoreEntry) error {
+	return s.append(entries)
+}
+
+// Len returns the number of stored keys.
+func (s *fileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data)
+}
+
+// Close syncs and closes the file.
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Sync()
+	if e := s.f.Close(); err == nil {
+		err = e
+	}
+	s.f = nil
+	return err
+}
@@ -0,0 +1,61 @@
+package HighPerformanceMap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := CreateConcurrentSliceMap(7).BindStore(store, StoreConfig{Mode: WriteBehind})
+	s.Set(StrKey("Hello"), "World")
+	s.Set(I64Key(42), []any{"a", "b"})
+	s.Set(StrKey("gone"), 1)
+	s.Delete(StrKey("gone"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.Len() != 2 {
+		t.Errorf("len after reopen --> %v, want 2", store.Len())
+	}
+
+	s = CreateConcurrentSliceMap(7).BindStore(store, StoreConfig{})
+	if v, ok, err := s.Get(StrKey("Hello")); v != "World" || !ok || err != nil {
+		t.Errorf("get Hello --> %v, %v, %v", v, ok, err)
+	}
+	if v, ok, _ := s.Get(I64Key(42)); !ok || len(v.([]any)) != 2 {
+		t.Errorf("get 42 --> %v, %v", v, ok)
+	}
+	if _, ok, _ := s.Get(StrKey("gone")); ok {
+		t.Error("deleted key found after reopen")
+	}
+
+	if err := store.Save(PairKey(StrKey("a"), StrKey("b")), 1); err == nil {
+		t.Error("Save of unsupported key type succeeded")
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+	if err := os.WriteFile(path, []byte("{not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(path); err == nil {
+		t.Error("corrupt file opened")
+	}
+}
@@ -0,0 +1,22 @@
+package HighPerformanceMap
+
+type int32Key struct {
+	value int32
+}
+
+func (i *int32Key) PartitionKey() uint64 {
+	return uint64(i.value)
+}
+
+func (i *int32Key) Value() any {
+	return i.value
+}
+
+func (i *int32Key) equal(stored any) bool {
+	v, ok := stored.(int32)
+	return ok && v == i.value
+}
+
+func I32Key(key int32) *int32Key {
+	return &int32Key{key}
+}
@@ -13,6 +13,11 @@ func (i *int64Key) Value() any {
 	return i.value
 }
 
+func (i *int64Key) equal(stored any) bool {
+	v, ok := stored.(uint64)
+	return ok && v == i.value
+}
+
 func I64Key(key int64) *int64Key {
 	return &int64Key{uint64(key)}
 }
@@ -0,0 +1,220 @@
+package HighPerformanceMap
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// int64Map 专门保存 int64 → int64，每个分区是线性探测的开放寻址表，
+// key 和 value 交替放在 []uint64 中，不含指针，GC 不需要扫描。每个位置占 16 字节，
+// 装载因子超过 3/4 时扩大一倍，删除后低于 3/16 时缩小一半，所以装载因子在 3/16 到 3/4 之间，
+// 每个 key 实际占 21 到 85 字节；每个分区至少 int64MapMinSlots 个位置，key 很少时占用更多
+type int64Map struct {
+	partitions  []*int64Partition
+	lenOfBucket int
+	count       int64 // key 的数量，原子操作
+}
+
+type int64Partition struct {
+	mu      sync.RWMutex
+	slots   []uint64 // slots[2*i] 为 key，slots[2*i+1] 为 value，key 为 0 表示空位
+	used    int      // slots 中 key 的数量
+	hasZero bool     // key 0 不能放进 slots，单独保存
+	zero    uint64
+}
+
+const int64MapMinSlots = 8
+
+// CreateInt64Map creates a map from int64 to int64 whose storage contains no
+// pointers, for counters and other integer data with many keys. Each slot
+// takes 16 bytes. A table grows when it gets over 3/4 full and shrinks when
+// deletes leave it under 3/16 full, so a key costs about 21 to 85 bytes.
+// Every partition keeps at least 8 slots (128 bytes), so partitions holding
+// only a few keys cost more per key.
+func CreateInt64Map(lenOfBucket int) *int64Map {
+	m := &int64Map{
+		partitions:  make([]*int64Partition, lenOfBucket),
+		lenOfBucket: lenOfBucket,
+	}
+	for i := range m.partitions {
+		m.partitions[i] = &int64Partition{slots: make([]uint64, 2*int64MapMinSlots)}
+	}
+	return m
+}
+
+// partition 用 hash 选择分区，分区内用旋转后的 hash 选择位置，两者互不相关
+func (m *int64Map) partition(key int64) (*int64Partition, uint64) {
+	h := mix64(uint64(key))
+	return m.partitions[h%uint64(m.lenOfBucket)], bits.RotateLeft64(h, 32)
+}
+
+func (m *int64Map) Get(key int64) (int64, bool) {
+	p, h := m.partition(key)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	v, ok := p.get(uint64(key), h)
+	return int64(v), ok
+}
+
+func (m *int64Map) Set(key, value int64) {
+	p, h := m.partition(key)
+	p.mu.Lock()
+	_, ok := p.set(uint64(key), h, uint64(value))
+	p.mu.Unlock()
+	if !ok {
+		atomic.AddInt64(&m.count, 1)
+	}
+}
+
+// Add adds delta to the value of key, treating a missing key as 0, and
+// returns the new value.
+func (m *int64Map) Add(key, delta int64) int64 {
+	p, h := m.partition(key)
+	p.mu.Lock()
+	v, _ := p.get(uint64(key), h)
+	v += uint64(delta)
+	_, ok := p.set(uint64(key), h, v)
+	p.mu.Unlock()
+	if !ok {
+		atomic.AddInt64(&m.count, 1)
+	}
+	return int64(v)
+}
+
+func (m *int64Map) Delete(key int64) {
+	p, h := m.partition(key)
+	p.mu.Lock()
+	ok := p.delete(uint64(key), h)
+	p.mu.Unlock()
+	if ok {
+		atomic.AddInt64(&m.count, -1)
+	}
+}
+
+func (m *int64Map) Len() int {
+	return int(atomic.LoadInt64(&m.count))
+}
+
+// Range 遍历所有 key，f 执行时持有所在分区的读锁
+func (m *int64Map) Range(f func(key, value int64) bool) {
+	for _, p := range m.partitions {
+		p.mu.RLock()
+		ok := p.rangeData(f)
+		p.mu.RUnlock()
+		if !ok {
+			return
+		}
+	}
+}
+
+func (p *int64Partition) mask() uint64 {
+	return uint64(len(p.slots)/2 - 1)
+}
+
+func (p *int64Partition) get(key, h uint64) (uint64, bool) {
+	if key == 0 {
+		return p.zero, p.hasZero
+	}
+
+	mask := p.mask()
+	for i := h & mask; ; i = (i + 1) & mask {
+		switch p.slots[2*i] {
+		case key:
+			return p.slots[2*i+1], true
+		case 0:
+			return 0, false
+		}
+	}
+}
+
+// set 写入 key，返回旧值以及 key 是否已存在
+func (p *int64Partition) set(key, h, value uint64) (uint64, bool) {
+	if key == 0 {
+		old, ok := p.zero, p.hasZero
+		p.zero, p.hasZero = value, true
+		return old, ok
+	}
+
+	mask := p.mask()
+	i := h & mask
+	for ; p.slots[2*i] != 0; i = (i + 1) & mask {
+		if p.slots[2*i] == key {
+			old := p.slots[2*i+1]
+			p.slots[2*i+1] = value
+			return old, true
+		}
+	}
+	p.slots[2*i], p.slots[2*i+1] = key, value
+	p.used++
+	// 装载因子超过 3/4 时扩容
+	if 4*p.used > 3*(len(p.slots)/2) {
+		p.resize(2 * len(p.slots))
+	}
+	return 0, false
+}
+
+// resize 把所有 key 重新放入 n/2 个位置的新表
+func (p *int64Partition) resize(n int) {
+	old := p.slots
+	p.slots = make([]uint64, n)
+	mask := p.mask()
+	for j := 0; j < len(old); j += 2 {
+		key := old[j]
+		if key == 0 {
+			continue
+		}
+		i := bits.RotateLeft64(mix64(key), 32) & mask
+		for p.slots[2*i] != 0 {
+			i = (i + 1) & mask
+		}
+		p.slots[2*i], p.slots[2*i+1] = key, old[j+1]
+	}
+}
+
+// delete 删除 key 后把后面的元素向前移，不需要墓碑
+func (p *int64Partition) delete(key, h uint64) bool {
+	if key == 0 {
+		ok := p.hasZero
+		p.zero, p.hasZero = 0, false
+		return ok
+	}
+
+	mask := p.mask()
+	i := h & mask
+	for p.slots[2*i] != key {
+		if p.slots[2*i] == 0 {
+			return false
+		}
+		i = (i + 1) & mask
+	}
+
+	for j := (i + 1) & mask; p.slots[2*j] != 0; j = (j + 1) & mask {
+		home := bits.RotateLeft64(mix64(p.slots[2*j]), 32) & mask
+		// home 不在 (i, j] 之间时，j 可以移到 i
+		if (j > i && (home <= i || home > j)) || (j < i && home <= i && home > j) {
+			p.slots[2*i], p.slots[2*i+1] = p.slots[2*j], p.slots[2*j+1]
+			i = j
+		}
+	}
+	p.slots[2*i], p.slots[2*i+1] = 0, 0
+	p.used--
+	// 装载因子低于 3/16 时缩小一半，缩小后低于 3/8，不会马上再扩容
+	if n := len(p.slots) / 2; n > int64MapMinSlots && 16*p.used < 3*n {
+		p.resize(len(p.slots) / 2)
+	}
+	return true
+}
+
+func (p *int64Partition) rangeData(f func(key, value int64) bool) bool {
+	if p.hasZero && !f(0, int64(p.zero)) {
+		return false
+	}
+	for j := 0; j < len(p.slots); j += 2 {
+		if p.slots[j] != 0 && !f(int64(p.slots[j]), int64(p.slots[j+1])) {
+			return false
+		}
+	}
+	return true
+}
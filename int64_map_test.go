@@ -0,0 +1,141 @@
+package HighPerformanceMap
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestInt64Map(t *testing.T) {
+	mapData := CreateInt64Map(4)
+	want := make(map[int64]int64)
+	r := rand.New(rand.NewSource(1))
+
+	// 与内置 map 对比，key 范围小以便覆盖覆盖写和删除后的移位
+	for i := 0; i < 100000; i++ {
+		key := r.Int63n(2000) - 1000
+		switch r.Intn(3) {
+		case 0:
+			mapData.Set(key, int64(i))
+			want[key] = int64(i)
+		case 1:
+			mapData.Delete(key)
+			delete(want, key)
+		default:
+			v, ok := mapData.Get(key)
+			if w, wok := want[key]; ok != wok || v != w {
+				t.Fatalf("get %v --> %v, %v, want %v, %v", key, v, ok, w, wok)
+			}
+		}
+	}
+
+	if mapData.Len() != len(want) {
+		t.Errorf("len --> %v, want %v", mapData.Len(), len(want))
+	}
+	n := 0
+	mapData.Range(func(key, value int64) bool {
+		n++
+		if want[key] != value {
+			t.Errorf("range %v --> %v", key, value)
+		}
+		return true
+	})
+	if n != len(want) {
+		t.Errorf("range --> %v", n)
+	}
+}
+
+func TestInt64MapAdd(t *testing.T) {
+	mapData := CreateInt64Map(8)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				mapData.Add(int64(i%100), 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 100; i++ {
+		if v, ok := mapData.Get(int64(i)); !ok || v != 800 {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if mapData.Len() != 100 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
+
+func TestInt64MapMemory(t *testing.T) {
+	num := 1000000
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	mapData := CreateInt64Map(16)
+	for i := 0; i < num; i++ {
+		mapData.Set(int64(i), int64(i))
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	// 装载因子在 3/8 到 3/4 之间，每个 key 最多 16 / (3/8) 字节
+	perKey := float64(after.HeapAlloc-before.HeapAlloc) / float64(num)
+	t.Logf("bytes per key --> %.1f", perKey)
+	if perKey > 16/0.375+1 {
+		t.Errorf("bytes per key --> %.1f", perKey)
+	}
+	runtime.KeepAlive(mapData)
+}
+
+func BenchmarkInt64MapSet(b *testing.B) {
+	mapData := CreateInt64Map(16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mapData.Set(int64(i), int64(i))
+	}
+}
+
+func BenchmarkInt64MapGet(b *testing.B) {
+	num := 100000
+	mapData := CreateInt64Map(16)
+	for i := 0; i < num; i++ {
+		mapData.Set(int64(i), int64(i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			mapData.Get(int64(i % num))
+			i++
+		}
+	})
+}
+
+func TestInt64MapShrink(t *testing.T) {
+	mapData := CreateInt64Map(1)
+	p := mapData.partitions[0]
+	for i := 1; i <= 10000; i++ {
+		mapData.Set(int64(i), int64(i))
+	}
+	grown := len(p.slots)
+	for i := 1; i <= 9990; i++ {
+		mapData.Delete(int64(i))
+	}
+	if len(p.slots) >= grown/64 {
+		t.Errorf("slots --> %v after deletes, %v before", len(p.slots), grown)
+	}
+	for i := 9991; i <= 10000; i++ {
+		if v, ok := mapData.Get(int64(i)); !ok || v != int64(i) {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if mapData.Len() != 10 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
@@ -0,0 +1,149 @@
+package HighPerformanceMap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// JSONKeyKind 决定反序列化时 JSON 对象的 key 使用哪种 Partitionable
+type JSONKeyKind int
+
+const (
+	JSONStringKey JSONKeyKind = iota // key 使用 StrKey
+	JSONInt64Key                     // key 是数字字符串，使用 I64Key
+)
+
+// RegisterJSONType registers how UnmarshalJSON decodes entries: keys are built
+// according to kind and values are decoded into the type of sample. A nil
+// sample decodes values the way encoding/json decodes into an interface{}.
+func (m *concurrentMap) RegisterJSONType(kind JSONKeyKind, sample any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jsonKeyKind = kind
+	m.jsonValueType = nil
+	if sample != nil {
+		m.jsonValueType = reflect.TypeOf(sample)
+	}
+}
+
+func encodeJSONKey(key any) ([]byte, error) {
+	switch k := key.(type) {
+	case string:
+		return json.Marshal(k)
+	case uint64:
+		return json.Marshal(strconv.FormatInt(int64(k), 10))
+	}
+	return nil, fmt.Errorf("HighPerformanceMap: unsupported json key type %T", key)
+}
+
+// EncodeJSON writes the map to w as a JSON object, one entry at a time,
+// without building the whole document in memory. Each partition is
+// read-locked while its entries are encoded, and migrating partitions to a
+// new size is paused until the encoding ends: writers to the partition being
+// encoded wait, and a write that starts a resize waits for the whole
+// encoding, holding up the operations behind it. The output is not a
+// point-in-time snapshot: a write made during the encoding may or may not be
+// included.
+func (m *concurrentMap) EncodeJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := bw.WriteByte('{'); err != nil {
+		return err
+	}
+
+	var err error
+	first := true
+	m.Range(func(key, value any) bool {
+		var k, v []byte
+		if k, err = encodeJSONKey(key); err != nil {
+			return false
+		}
+		if v, err = json.Marshal(value); err != nil {
+			return false
+		}
+
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		bw.Write(k)
+		bw.WriteByte(':')
+		_, err = bw.Write(v)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = bw.WriteByte('}'); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (m *concurrentMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.EncodeJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON stores every entry of a JSON object into the map, keeping the
+// entries that are already present, like encoding/json does for Go maps.
+func (m *concurrentMap) UnmarshalJSON(data []byte) error {
+	m.mu.RLock()
+	kind, valueType := m.jsonKeyKind, m.jsonValueType
+	m.mu.RUnlock()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t == nil {
+		return nil
+	} else if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("HighPerformanceMap: cannot unmarshal %v into map", t)
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := t.(string)
+
+		var key Partitionable
+		switch kind {
+		case JSONInt64Key:
+			n, err := strconv.ParseInt(name, 10, 64)
+			if err != nil {
+				return fmt.Errorf("HighPerformanceMap: invalid int64 key %q", name)
+			}
+			key = I64Key(n)
+		default:
+			key = StrKey(name)
+		}
+
+		var value any
+		if valueType == nil {
+			err = dec.Decode(&value)
+		} else {
+			ptr := reflect.New(valueType)
+			err = dec.Decode(ptr.Interface())
+			value = ptr.Elem().Interface()
+		}
+		if err != nil {
+			return err
+		}
+
+		m.Set(key, value)
+	}
+
+	_, err := dec.Token()
+	return err
+}
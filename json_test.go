@@ -0,0 +1,85 @@
+package HighPerformanceMap
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSONString(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.Set(StrKey("Hello"), 123)
+	mapData.Set(StrKey("World"), "jinjin")
+
+	data, err := json.Marshal(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out map[string]any
+	if err = json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out["Hello"].(float64) != 123 || out["World"].(string) != "jinjin" {
+		t.Errorf("marshal failed: %s", data)
+	}
+}
+
+func TestMarshalJSONInt64(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.Set(I64Key(-7), "minus")
+
+	data, err := json.Marshal(mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"-7":"minus"}` {
+		t.Errorf("marshal failed: %s", data)
+	}
+}
+
+func TestUnmarshalJSONInt64(t *testing.T) {
+	type config struct {
+		Name string
+		Size int
+	}
+
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.RegisterJSONType(JSONInt64Key, config{})
+
+	err := json.Unmarshal([]byte(`{"1":{"Name":"a","Size":1},"-2":{"Name":"b","Size":2}}`), mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := mapData.Get(I64Key(-2))
+	if !ok || v.(config).Name != "b" || v.(config).Size != 2 {
+		t.Errorf("unmarshal failed: %v", v)
+	}
+
+	if err = json.Unmarshal([]byte(`{"x":{}}`), mapData); err == nil {
+		t.Error("invalid int64 key accepted")
+	}
+}
+
+func TestEncodeJSONRoundTrip(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	mapData.Set(StrKey("a\"b"), []int{1, 2})
+	mapData.Set(StrKey("c"), []int{3})
+
+	var buf bytes.Buffer
+	if err := mapData.EncodeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	copyData := CreateConcurrentSliceMap(9)
+	copyData.RegisterJSONType(JSONStringKey, []int{})
+	if err := json.Unmarshal(buf.Bytes(), copyData); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := copyData.Get(StrKey("a\"b"))
+	if !ok || len(v.([]int)) != 2 || copyData.Len() != 2 {
+		t.Errorf("round trip failed: %s", buf.String())
+	}
+}
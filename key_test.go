@@ -0,0 +1,145 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"testing"
+)
+
+// collideKey 所有 key 的 hash 都相同
+type collideKey string
+
+func (c collideKey) PartitionKey() uint64 {
+	return 7
+}
+
+func (c collideKey) Value() any {
+	return string(c)
+}
+
+func TestKeyCollision(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	for i := 0; i < 10; i++ {
+		mapData.Set(collideKey(strconv.Itoa(i)), i)
+	}
+	mapData.Delete(collideKey("0"))
+	mapData.Delete(collideKey("5"))
+	mapData.Delete(collideKey("9"))
+	mapData.Set(collideKey("5"), 50)
+
+	if mapData.Len() != 8 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+	for i := 1; i < 9; i++ {
+		v, ok := mapData.Get(collideKey(strconv.Itoa(i)))
+		if want := i; !ok || (i != 5 && v.(int) != want) || (i == 5 && v.(int) != 50) {
+			t.Errorf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if _, ok := mapData.Get(collideKey("9")); ok {
+		t.Error("deleted key found")
+	}
+
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 8 {
+		t.Errorf("range --> %v", n)
+	}
+}
+
+func TestBuiltinKeys(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	uuid := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	mapData.Set(I64Key(5), "i64")
+	mapData.Set(I32Key(5), "i32")
+	mapData.Set(UUIDKey(uuid), "uuid")
+	mapData.Set(PairKey(I64Key(42), StrKey("a")), "pair")
+	mapData.Set(PairKey(StrKey("a"), I64Key(42)), "reversed")
+	mapData.Set(BytesKey([]byte("Hello")), "bytes")
+
+	cases := []struct {
+		key  Partitionable
+		want string
+	}{
+		{I64Key(5), "i64"},
+		{U64Key(5), "i64"},
+		{I32Key(5), "i32"},
+		{UUIDKey(uuid), "uuid"},
+		{PairKey(I64Key(42), StrKey("a")), "pair"},
+		{PairKey(StrKey("a"), I64Key(42)), "reversed"},
+		{StrKey("Hello"), "bytes"},
+	}
+	for _, c := range cases {
+		if v, ok := mapData.Get(c.key); !ok || v.(string) != c.want {
+			t.Errorf("get %v --> %v, want %v", c.key.Value(), v, c.want)
+		}
+	}
+	if _, ok := mapData.Get(PairKey(I64Key(42), StrKey("b"))); ok {
+		t.Error("pair key matched a different tuple")
+	}
+	if mapData.Len() != 6 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
+
+func BenchmarkKeyConstruct(b *testing.B) {
+	uuid := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	data := []byte("tenant:42:user:7")
+
+	b.Run("U64Key", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = U64Key(uint64(i)).PartitionKey()
+		}
+	})
+	b.Run("I32Key", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = I32Key(int32(i)).PartitionKey()
+		}
+	})
+	b.Run("BytesKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = BytesKey(data).PartitionKey()
+		}
+	})
+	b.Run("UUIDKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = UUIDKey(uuid).PartitionKey()
+		}
+	})
+	b.Run("PairKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = PairKey(I64Key(int64(i)), StrKey("a")).PartitionKey()
+		}
+	})
+}
+
+func BenchmarkKeyGet(b *testing.B) {
+	num := 100
+	mapData := CreateConcurrentSliceMap(99)
+	for i := 0; i < num; i++ {
+		mapData.Set(PairKey(I64Key(int64(i)), StrKey("a")), i)
+		mapData.Set(BytesKey([]byte(strconv.Itoa(i))), i)
+	}
+	keys := make([][]byte, num)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+
+	b.Run("BytesKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := mapData.Get(BytesKey(keys[i%num])); !ok {
+				b.Errorf("error %v", i)
+			}
+		}
+	})
+	b.Run("PairKey", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := mapData.Get(PairKey(I64Key(int64(i%num)), StrKey("a"))); !ok {
+				b.Errorf("error %v", i)
+			}
+		}
+	})
+}
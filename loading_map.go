@@ -0,0 +1,241 @@
+package HighPerformanceMap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Loader when the key does not exist. It is
+// cached for LoadingConfig.NegativeTTL so missing keys do not reach the
+// loader on every Get.
+var ErrNotFound = errors.New("HighPerformanceMap: key not found")
+
+// Loader loads the value of a key missing from a LoadingMap. ctx is
+// cancelled when every caller waiting for the key has given up.
+type Loader func(ctx context.Context, key Partitionable) (any, error)
+
+// LoadingConfig 加载结果的缓存时间，零值表示成功的结果永不过期、不缓存 ErrNotFound
+type LoadingConfig struct {
+	TTL          time.Duration    // 成功加载的值的过期时间，0 表示不过期
+	NegativeTTL  time.Duration    // 缓存 ErrNotFound 的时间，0 表示不缓存
+	RefreshAhead float64          // 值的存在时间超过 TTL 的该比例后在后台刷新，如 0.8，0 表示关闭
+	StaleGrace   time.Duration    // 过期后重新加载失败时，继续返回旧值的时间，0 表示关闭
+	Clock        func() time.Time // 默认 time.Now
+}
+
+// loadingMap 在 concurrentMap 之上合并同一个 key 的并发加载，同一时间每个 key 最多调用一次 Loader
+type loadingMap struct {
+	m      *concurrentMap // 值为 *loadedEntry
+	loader Loader
+	cfg    LoadingConfig
+
+	mu    sync.Mutex
+	calls map[any]*loadCall // key.Value() → 正在进行的加载
+}
+
+// loadCall 一次正在进行的加载，done 关闭后 value 和 err 不再修改
+type loadCall struct {
+	done    chan struct{}
+	value   any
+	err     error
+	waiters int  // 等待结果的调用方数量，由 loadingMap.mu 保护
+	stale   bool // 加载期间 key 被 Set 或 Delete，结果不写入缓存，由 loadingMap.mu 保护
+	cancel  context.CancelFunc
+}
+
+// loadedEntry 缓存的加载结果，创建后不再修改。expireAt 之后 concurrentMap 中的值
+// 还会保留 StaleGrace，用于加载失败时返回旧值
+type loadedEntry struct {
+	value     any   // notFoundEntry 表示缓存的 ErrNotFound
+	refreshAt int64 // 之后的 Get 在后台刷新，0 表示不刷新
+	expireAt  int64 // 之后的 Get 需要重新加载，0 表示不过期
+}
+
+// notFoundEntry 缓存 ErrNotFound 时保存的值
+type notFoundEntry struct{}
+
+func (e *loadedEntry) result() (any, error) {
+	if _, ok := e.value.(notFoundEntry); ok {
+		return nil, ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (e *loadedEntry) fresh(now int64) bool {
+	return e.expireAt == 0 || now < e.expireAt
+}
+
+// CreateLoadingMap creates a cache that calls loader for missing keys.
+// Concurrent Gets of the same missing key share one loader call.
+func CreateLoadingMap(lenOfBucket int, loader Loader, cfg LoadingConfig) *loadingMap {
+	m := CreateConcurrentSliceMap(lenOfBucket)
+	m.SetClock(cfg.Clock)
+	return &loadingMap{
+		m:      m,
+		loader: loader,
+		cfg:    cfg,
+		calls:  make(map[any]*loadCall),
+	}
+}
+
+func (l *loadingMap) entry(key Partitionable) *loadedEntry {
+	if v, ok := l.m.Get(key); ok {
+		return v.(*loadedEntry)
+	}
+	return nil
+}
+
+// Get returns the cached value of key, or loads it. The loader runs once for
+// all concurrent callers; a caller whose ctx is done stops waiting and gets
+// ctx.Err(), and the load is cancelled once no caller is waiting. Loader
+// errors are returned to every waiting caller and only ErrNotFound is
+// cached.
+//
+// With RefreshAhead, a value older than that fraction of its TTL is still
+// returned while it is reloaded in the background. With StaleGrace, an
+// expired value is returned instead of the loader's error for that long
+// after it expired.
+func (l *loadingMap) Get(ctx context.Context, key Partitionable) (any, error) {
+	if e := l.entry(key); e != nil {
+		if now := l.m.now(); e.fresh(now) {
+			if e.refreshAt != 0 && now >= e.refreshAt {
+				l.refresh(key)
+			}
+			return e.result()
+		}
+	}
+
+	k := key.Value()
+	l.mu.Lock()
+	// 加锁后再查一次，上一次加载可能刚刚完成，e 不为 nil 时是宽限期内的旧值
+	e := l.entry(key)
+	if e != nil && e.fresh(l.m.now()) {
+		l.mu.Unlock()
+		return e.result()
+	}
+	c := l.start(key)
+	c.waiters++
+	l.mu.Unlock()
+
+	select {
+	case <-c.done:
+		if c.err != nil && e != nil && !errors.Is(c.err, ErrNotFound) {
+			return e.result()
+		}
+		return c.value, c.err
+	case <-ctx.Done():
+		l.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			// 之后的调用方重新加载，不等待已经取消的加载
+			if l.calls[k] == c {
+				delete(l.calls, k)
+				c.stale = true
+			}
+		}
+		l.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// start 返回 key 正在进行的加载，没有时开始一次，调用方持有 l.mu
+func (l *loadingMap) start(key Partitionable) *loadCall {
+	k := key.Value()
+	if c := l.calls[k]; c != nil {
+		return c
+	}
+	c := &loadCall{done: make(chan struct{})}
+	var ctx context.Context
+	// 加载不跟随第一个调用方的 ctx，只在所有调用方都放弃后取消
+	ctx, c.cancel = context.WithCancel(context.Background())
+	l.calls[k] = c
+	go l.load(ctx, key, c)
+	return c
+}
+
+// refresh 在后台重新加载 key，已经在加载时不做任何事
+func (l *loadingMap) refresh(key Partitionable) {
+	l.mu.Lock()
+	if l.calls[key.Value()] == nil {
+		// 后台刷新算作一个不会放弃的调用方，Get 的调用方放弃时不会取消它
+		l.start(key).waiters++
+	}
+	l.mu.Unlock()
+}
+
+func (l *loadingMap) load(ctx context.Context, key Partitionable, c *loadCall) {
+	defer c.cancel()
+	c.value, c.err = l.loader(ctx, key)
+
+	k := key.Value()
+	l.mu.Lock()
+	if !c.stale {
+		l.store(key, c.value, c.err)
+	}
+	if l.calls[k] == c {
+		delete(l.calls, k)
+	}
+	l.mu.Unlock()
+	close(c.done)
+}
+
+// store 写入加载结果，其它错误不缓存，缓存中的旧值保留
+func (l *loadingMap) store(key Partitionable, v any, err error) {
+	switch {
+	case err == nil:
+		l.set(key, v, l.cfg.TTL, l.cfg.StaleGrace)
+	case errors.Is(err, ErrNotFound) && l.cfg.NegativeTTL > 0:
+		l.set(key, notFoundEntry{}, l.cfg.NegativeTTL, 0)
+	}
+}
+
+func (l *loadingMap) set(key Partitionable, v any, ttl, grace time.Duration) {
+	e := &loadedEntry{value: v}
+	if ttl <= 0 {
+		l.m.Set(key, e)
+		return
+	}
+
+	now := l.m.now()
+	e.expireAt = now + int64(ttl)
+	if r := l.cfg.RefreshAhead; r > 0 && r < 1 {
+		e.refreshAt = now + int64(float64(ttl)*r)
+	}
+	l.m.SetWithTTL(key, e, ttl+grace)
+}
+
+// forget 使正在进行的加载的结果不写入缓存，调用方持有 l.mu
+func (l *loadingMap) forget(key Partitionable) {
+	k := key.Value()
+	if c := l.calls[k]; c != nil {
+		c.stale = true
+		delete(l.calls, k)
+	}
+}
+
+// Set caches v for key with the configured TTL. A load of key already in
+// progress still returns its result to its callers but does not overwrite v.
+func (l *loadingMap) Set(key Partitionable, v any) {
+	l.mu.Lock()
+	l.forget(key)
+	l.set(key, v, l.cfg.TTL, l.cfg.StaleGrace)
+	l.mu.Unlock()
+}
+
+// Invalidate removes the cached value of key, so the next Get loads it
+// again.
+func (l *loadingMap) Invalidate(key Partitionable) {
+	l.mu.Lock()
+	l.forget(key)
+	l.m.Delete(key)
+	l.mu.Unlock()
+}
+
+// Len returns the number of cached entries, including cached ErrNotFound
+// results and expired values kept for StaleGrace.
+func (l *loadingMap) Len() int {
+	return l.m.Len()
+}
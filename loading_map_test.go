@@ -0,0 +1,292 @@
+package HighPerformanceMap
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitWaiters 等待 key 正在进行的加载有 n 个调用方在等待
+func waitWaiters(l *loadingMap, key any, n int) {
+	for {
+		l.mu.Lock()
+		c := l.calls[key]
+		ok := c != nil && c.waiters == n
+		l.mu.Unlock()
+		if ok {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestLoadingMapSingleflight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v:" + key.Value().(string), nil
+	}, LoadingConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.Get(context.Background(), StrKey("Hello"))
+			if err != nil || v != "v:Hello" {
+				t.Errorf("get --> %v, %v", v, err)
+			}
+		}()
+	}
+	// 所有调用方都在等待同一次加载之后才让它完成
+	waitWaiters(l, "Hello", 50)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("loader calls --> %v, want 1", calls)
+	}
+	if v, err := l.Get(context.Background(), StrKey("Hello")); err != nil || v != "v:Hello" || calls != 1 {
+		t.Errorf("cached get --> %v, %v, loader calls --> %v", v, err, calls)
+	}
+	if l.Len() != 1 {
+		t.Errorf("len --> %v, want 1", l.Len())
+	}
+}
+
+func TestLoadingMapErrors(t *testing.T) {
+	clock := newFakeClock()
+	var calls int32
+	errDB := errors.New("db down")
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		if key.Value() == "missing" {
+			return nil, ErrNotFound
+		}
+		return nil, errDB
+	}, LoadingConfig{NegativeTTL: 20 * time.Millisecond, Clock: clock.Now})
+
+	for i := 0; i < 3; i++ {
+		if _, err := l.Get(context.Background(), StrKey("broken")); err != errDB {
+			t.Fatalf("get broken --> %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("loader calls for a failing key --> %v, want 3", calls)
+	}
+
+	calls = 0
+	for i := 0; i < 3; i++ {
+		if _, err := l.Get(context.Background(), StrKey("missing")); err != ErrNotFound {
+			t.Fatalf("get missing --> %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader calls for a cached miss --> %v, want 1", calls)
+	}
+	clock.Advance(40 * time.Millisecond)
+	l.Get(context.Background(), StrKey("missing"))
+	if calls != 2 {
+		t.Errorf("loader calls after negative TTL --> %v, want 2", calls)
+	}
+}
+
+func TestLoadingMapTTL(t *testing.T) {
+	clock := newFakeClock()
+	var calls int32
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		return atomic.AddInt32(&calls, 1), nil
+	}, LoadingConfig{TTL: 20 * time.Millisecond, Clock: clock.Now})
+
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != int32(1) {
+		t.Fatalf("get --> %v", v)
+	}
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != int32(1) {
+		t.Errorf("get before TTL --> %v, want cached 1", v)
+	}
+	clock.Advance(40 * time.Millisecond)
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != int32(2) {
+		t.Errorf("get after TTL --> %v, want reloaded 2", v)
+	}
+
+	l.Set(StrKey("Hello"), int32(10))
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != int32(10) {
+		t.Errorf("get after set --> %v", v)
+	}
+	l.Invalidate(StrKey("Hello"))
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != int32(3) {
+		t.Errorf("get after invalidate --> %v, want reloaded 3", v)
+	}
+}
+
+func TestLoadingMapCancel(t *testing.T) {
+	cancelled := make(chan struct{})
+	loadCtx := make(chan context.Context, 1)
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		loadCtx <- ctx
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}, LoadingConfig{})
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for _, ctx := range []context.Context{ctx1, ctx2} {
+		go func(ctx context.Context) {
+			_, err := l.Get(ctx, StrKey("Hello"))
+			errs <- err
+		}(ctx)
+	}
+	waitWaiters(l, "Hello", 2)
+	ctx := <-loadCtx
+
+	cancel1()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("get err --> %v, want context.Canceled", err)
+	}
+	// 第一个调用方放弃时已经减少了 waiters，还有调用方等待时不取消加载
+	waitWaiters(l, "Hello", 1)
+	if ctx.Err() != nil {
+		t.Fatal("load cancelled while a caller is still waiting")
+	}
+
+	cancel2()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("get err --> %v, want context.Canceled", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("load not cancelled after every caller gave up")
+	}
+	if l.Len() != 0 {
+		t.Errorf("len --> %v, cancelled load was cached", l.Len())
+	}
+}
+
+func TestLoadingMapSetDuringLoad(t *testing.T) {
+	release := make(chan struct{})
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		<-release
+		return "loaded", nil
+	}, LoadingConfig{})
+
+	done := make(chan any)
+	go func() {
+		v, _ := l.Get(context.Background(), StrKey("Hello"))
+		done <- v
+	}()
+	waitWaiters(l, "Hello", 1)
+	l.Set(StrKey("Hello"), "set")
+	close(release)
+
+	if v := <-done; v != "loaded" {
+		t.Errorf("waiting get --> %v, want loaded", v)
+	}
+	if v, _ := l.Get(context.Background(), StrKey("Hello")); v != "set" {
+		t.Errorf("get --> %v, load overwrote set", v)
+	}
+}
+
+func TestLoadingMapRefreshAhead(t *testing.T) {
+	clock := newFakeClock()
+	var calls int32
+	started := make(chan int32, 10)
+	release := make(chan struct{}, 10)
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		n := atomic.AddInt32(&calls, 1)
+		started <- n
+		<-release
+		return n, nil
+	}, LoadingConfig{TTL: 10 * time.Second, RefreshAhead: 0.8, Clock: clock.Now})
+	key := StrKey("config")
+
+	release <- struct{}{}
+	if v, err := l.Get(context.Background(), key); v != int32(1) || err != nil {
+		t.Fatalf("get --> %v, %v", v, err)
+	}
+	<-started
+
+	clock.Advance(7 * time.Second)
+	if v, _ := l.Get(context.Background(), key); v != int32(1) || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("get before refresh threshold --> %v, loader calls --> %v", v, calls)
+	}
+
+	// 超过 80% 的 TTL，返回旧值并在后台刷新，刷新期间不再开始新的加载
+	clock.Advance(time.Second)
+	for i := 0; i < 3; i++ {
+		if v, err := l.Get(context.Background(), key); v != int32(1) || err != nil {
+			t.Fatalf("get during refresh --> %v, %v", v, err)
+		}
+	}
+	if n := <-started; n != 2 {
+		t.Fatalf("refresh started load --> %v", n)
+	}
+	l.mu.Lock()
+	c := l.calls["config"]
+	l.mu.Unlock()
+	release <- struct{}{}
+	<-c.done
+
+	if v, _ := l.Get(context.Background(), key); v != int32(2) {
+		t.Errorf("get after refresh --> %v, want 2", v)
+	}
+	if calls != 2 {
+		t.Errorf("loader calls --> %v, want 2", calls)
+	}
+
+	// 刷新后的值重新计算刷新时间
+	clock.Advance(7 * time.Second)
+	if v, _ := l.Get(context.Background(), key); v != int32(2) || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("get --> %v, loader calls --> %v", v, calls)
+	}
+}
+
+func TestLoadingMapStaleGrace(t *testing.T) {
+	clock := newFakeClock()
+	errDB := errors.New("db down")
+	var fail, missing int32
+	l := CreateLoadingMap(7, func(ctx context.Context, key Partitionable) (any, error) {
+		switch {
+		case atomic.LoadInt32(&missing) == 1:
+			return nil, ErrNotFound
+		case atomic.LoadInt32(&fail) == 1:
+			return nil, errDB
+		}
+		return "fresh", nil
+	}, LoadingConfig{TTL: 10 * time.Second, StaleGrace: 5 * time.Second, Clock: clock.Now})
+	key := StrKey("config")
+
+	if v, err := l.Get(context.Background(), key); v != "fresh" || err != nil {
+		t.Fatalf("get --> %v, %v", v, err)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	clock.Advance(11 * time.Second)
+	if v, err := l.Get(context.Background(), key); v != "fresh" || err != nil {
+		t.Errorf("get in grace period --> %v, %v, want stale value", v, err)
+	}
+
+	clock.Advance(4 * time.Second)
+	if _, err := l.Get(context.Background(), key); err != errDB {
+		t.Errorf("get after grace period --> %v, want %v", err, errDB)
+	}
+
+	// 加载成功后重新开始计算过期时间
+	atomic.StoreInt32(&fail, 0)
+	if v, _ := l.Get(context.Background(), key); v != "fresh" {
+		t.Fatalf("get --> %v", v)
+	}
+	atomic.StoreInt32(&missing, 1)
+	clock.Advance(11 * time.Second)
+	if _, err := l.Get(context.Background(), key); err != ErrNotFound {
+		t.Errorf("get deleted key in grace period --> %v, want ErrNotFound", err)
+	}
+}
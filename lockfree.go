@@ -0,0 +1,200 @@
+package HighPerformanceMap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// partitionTable 无锁读时通过 m.table 原子地读取当前的分区表
+type partitionTable struct {
+	partitions    []*partition
+	oldPartitions []*partition
+}
+
+// partitionView 无锁读使用的 hash 表，与分区的内容同步更新。桶中的链表发布之后不再修改，
+// 写入时复制链表中被修改的节点之前的部分，再原子地替换桶头，读取方不加锁也不写共享内存。
+// key 的数量超过桶的数量时复制到两倍大小的新表，写入的代价平均为 O(1)
+type partitionView struct {
+	buckets  []unsafe.Pointer // *viewNode，原子操作
+	shift    uint             // 64 - log2(len(buckets))
+	count    int              // 由分区的写锁保护
+	migrated bool
+}
+
+type viewNode struct {
+	keyIndex uint64
+	data     *innerSlice
+	next     *viewNode
+}
+
+const minViewBuckets = 8
+
+var migratedView = &partitionView{migrated: true}
+
+// storeTable 切换分区表，调用方需持有 m.mu 的写锁
+func (m *concurrentMap) storeTable(partitions, oldPartitions []*partition) {
+	m.partitions = partitions
+	m.oldPartitions = oldPartitions
+	m.lenOfBucket = len(partitions)
+	m.table.Store(&partitionTable{partitions, oldPartitions})
+}
+
+func (m *concurrentMap) lockFree() bool {
+	return atomic.LoadInt32(&m.lockFreeReads) == 1
+}
+
+func newPartitionView(n int) *partitionView {
+	v := &partitionView{buckets: make([]unsafe.Pointer, minViewBuckets), shift: 61}
+	for len(v.buckets) < n {
+		v.buckets = make([]unsafe.Pointer, len(v.buckets)*2)
+		v.shift--
+	}
+	return v
+}
+
+// bucket 同一个分区中 keyIndex 的低位相同，用乘法散列的高位选择桶
+func (v *partitionView) bucket(keyIndex uint64) *unsafe.Pointer {
+	return &v.buckets[(keyIndex*0x9E3779B97F4A7C15)>>v.shift]
+}
+
+// publish 按分区的当前内容重建只读 hash 表，只在开启无锁读、创建和迁移分区时调用，
+// 调用方需持有写锁
+func (p *partition) publish() {
+	if p.migrated {
+		p.view.Store(migratedView)
+		return
+	}
+
+	v := newPartitionView(p.index.len())
+	p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+		for ; index >= 0; index = int(p.innerSlice[index].next) {
+			v.replace(keyIndex, p.innerSlice[index].key, p.innerSlice[index])
+		}
+		return true
+	})
+	p.view.Store(v)
+}
+
+// updateView 把 key 的写入同步到只读 hash 表，data 为 nil 表示删除，调用方需持有写锁
+func (p *partition) updateView(keyIndex uint64, key any, data *innerSlice) {
+	v := p.view.Load().(*partitionView)
+	v.replace(keyIndex, key, data)
+	if v.count <= len(v.buckets) {
+		return
+	}
+
+	grown := newPartitionView(v.count + 1)
+	for i := range v.buckets {
+		for x := (*viewNode)(v.buckets[i]); x != nil; x = x.next {
+			grown.replace(x.keyIndex, x.data.key, x.data)
+		}
+	}
+	p.view.Store(grown)
+}
+
+// replace 把桶中 key 的节点替换为 data，data 为 nil 时删除，调用方需持有分区的写锁
+func (v *partitionView) replace(keyIndex uint64, key any, data *innerSlice) {
+	b := v.bucket(keyIndex)
+	head, found := replaceNode((*viewNode)(atomic.LoadPointer(b)), keyIndex, func(stored any) bool {
+		return stored == key
+	}, data)
+	if found {
+		v.count--
+	}
+	if data != nil {
+		v.count++
+	}
+	atomic.StorePointer(b, unsafe.Pointer(head))
+}
+
+// replaceNode 返回把链表中 key 的节点替换为 data 的新链表以及 key 是否存在，data 为 nil 时删除。
+// 新链表为 data、key 之前的节点的副本、key 之后的节点，原链表不会被修改
+func replaceNode(head *viewNode, keyIndex uint64, equal func(stored any) bool, data *innerSlice) (*viewNode, bool) {
+	x := head
+	for x != nil && (x.keyIndex != keyIndex || !equal(x.data.key)) {
+		x = x.next
+	}
+
+	rest := head
+	if x != nil {
+		rest = x.next
+		var first, last *viewNode
+		for y := head; y != x; y = y.next {
+			n := &viewNode{keyIndex: y.keyIndex, data: y.data}
+			if last == nil {
+				first = n
+			} else {
+				last.next = n
+			}
+			last = n
+		}
+		if last != nil {
+			last.next, rest = rest, first
+		}
+	}
+	if data != nil {
+		rest = &viewNode{keyIndex: keyIndex, data: data, next: rest}
+	}
+	return rest, x != nil
+}
+
+// findNode 在链表中查找 key
+func findNode(head *viewNode, keyIndex uint64, equal func(stored any) bool) *innerSlice {
+	for x := head; x != nil; x = x.next {
+		if x.keyIndex == keyIndex && equal(x.data.key) {
+			return x.data
+		}
+	}
+	return nil
+}
+
+func (v *partitionView) get(keyIndex uint64, equal func(stored any) bool) (*innerSlice, bool) {
+	data := findNode((*viewNode)(atomic.LoadPointer(v.bucket(keyIndex))), keyIndex, equal)
+	return data, data != nil
+}
+
+// EnableLockFreeReads makes Get, GetString and GetInt64 read a lock-free
+// copy of each partition's hash index, so readers never take a lock or write
+// shared memory. Writers still serialize per partition and update the copy
+// in O(1): they replace the nodes of one bucket and publish it atomically.
+func (m *concurrentMap) EnableLockFreeReads() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lockFree() {
+		return
+	}
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.mu.Lock()
+			p.publish()
+			p.mu.Unlock()
+		}
+	}
+	atomic.StoreInt32(&m.lockFreeReads, 1)
+}
+
+func (m *concurrentMap) getLockFree(keyIndex uint64, equal func(stored any) bool) (any, bool) {
+	data, _ := m.loadView(keyIndex).get(keyIndex, equal)
+	m.touch(data)
+	if v, ok := m.liveValue(keyIndex, data); ok || m.disk == nil {
+		return v, ok
+	}
+	return m.promote(keyIndex, equal)
+}
+
+// loadView 不加锁找到 keyIndex 所在分区的只读副本
+func (m *concurrentMap) loadView(keyIndex uint64) *partitionView {
+	for {
+		t := m.table.Load().(*partitionTable)
+		if old := t.oldPartitions; old != nil {
+			if v := old[keyIndex%uint64(len(old))].view.Load().(*partitionView); !v.migrated {
+				return v
+			}
+		}
+		if v := t.partitions[keyIndex%uint64(len(t.partitions))].view.Load().(*partitionView); !v.migrated {
+			return v
+		}
+		// 读到的分区表已经被替换，重新读取
+	}
+}
@@ -0,0 +1,125 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLockFreeReads(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(4)
+	for i := 0; i < 100; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	mapData.EnableLockFreeReads()
+
+	for i := 0; i < 100; i++ {
+		if v, ok := mapData.GetString(strconv.Itoa(i)); !ok || v.(int) != i {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	mapData.Set(StrKey("1"), 100)
+	mapData.Delete(StrKey("2"))
+	if v, ok := mapData.Get(StrKey("1")); !ok || v.(int) != 100 {
+		t.Errorf("get after set --> %v, %v", v, ok)
+	}
+	if _, ok := mapData.Get(StrKey("2")); ok {
+		t.Error("deleted key found")
+	}
+
+	mapData.Resize(16)
+	for i := 3; i < 100; i++ {
+		if v, ok := mapData.GetString(strconv.Itoa(i)); !ok || v.(int) != i {
+			t.Fatalf("get during resize %v --> %v, %v", i, v, ok)
+		}
+	}
+	n := testing.AllocsPerRun(100, func() {
+		mapData.GetString("50")
+	})
+	if n != 0 {
+		t.Errorf("allocs --> %v", n)
+	}
+}
+
+func TestLockFreeReadsGoroutine(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(2)
+	mapData.SetResizePolicy(1, 8)
+	mapData.EnableLockFreeReads()
+	num := 2000
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				mapData.Set(I64Key(int64(g*num+i)), i)
+			}
+		}(g)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				// 写入之前可能读不到，读到的值必须正确
+				if v, ok := mapData.GetInt64(int64(g*num + i)); ok && v.(int) != i {
+					t.Errorf("get %v --> %v", g*num+i, v)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4*num; i++ {
+		if v, ok := mapData.GetInt64(int64(i)); !ok || v.(int) != i%num {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestLockFreeReadsCollide(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(1)
+	mapData.EnableLockFreeReads()
+	for i := 0; i < 10; i++ {
+		mapData.Set(collideKey(strconv.Itoa(i)), i)
+	}
+	// 删除链表中间、头部和尾部的 key
+	for _, k := range []string{"5", "0", "9"} {
+		mapData.Delete(collideKey(k))
+	}
+	mapData.Set(collideKey("3"), 30)
+
+	for i := 0; i < 10; i++ {
+		v, ok := mapData.Get(collideKey(strconv.Itoa(i)))
+		switch {
+		case i == 0 || i == 5 || i == 9:
+			if ok {
+				t.Errorf("deleted %v found", i)
+			}
+		case i == 3:
+			if !ok || v.(int) != 30 {
+				t.Errorf("get 3 --> %v, %v", v, ok)
+			}
+		case !ok || v.(int) != i:
+			t.Errorf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+}
+
+// 写入只复制一个桶，代价与分区中 key 的数量无关
+func BenchmarkLockFreeSet(b *testing.B) {
+	for _, num := range []int{1000, 100000} {
+		b.Run(strconv.Itoa(num), func(b *testing.B) {
+			mapData := CreateConcurrentSliceMap(1)
+			mapData.EnableLockFreeReads()
+			for i := 0; i < num; i++ {
+				mapData.Set(I64Key(int64(i)), i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mapData.Set(I64Key(int64(i%num)), i)
+			}
+		})
+	}
+}
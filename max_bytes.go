@@ -0,0 +1,234 @@
+package HighPerformanceMap
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Weigher returns the size in bytes accounted for an entry. key is the
+// Value of the Partitionable key.
+type Weigher func(key, value any) int64
+
+// entryOverhead innerSlice 和分区索引中每个 key 大约占用的字节数
+const entryOverhead = 64
+
+// DefaultWeigher estimates the memory used by an entry with reflection. It
+// follows pointers, slices, maps and interfaces a few levels deep and adds a
+// fixed overhead for the map's own bookkeeping.
+func DefaultWeigher(key, value any) int64 {
+	return entryOverhead + estimateSize(reflect.ValueOf(key), 0) + estimateSize(reflect.ValueOf(value), 0)
+}
+
+const maxEstimateDepth = 8
+
+// estimateSize 估算 v 占用的字节数，超过 maxEstimateDepth 层的引用不再计算，也避免循环引用
+func estimateSize(v reflect.Value, depth int) int64 {
+	if !v.IsValid() || depth > maxEstimateDepth {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Slice:
+		size := int64(v.Type().Size())
+		if v.IsNil() {
+			return size
+		}
+		return size + estimateElems(v, depth) + int64(v.Cap()-v.Len())*int64(v.Type().Elem().Size())
+	case reflect.Array:
+		return estimateElems(v, depth)
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateSize(iter.Key(), depth+1) + estimateSize(iter.Value(), depth+1)
+		}
+		return size
+	case reflect.Pointer, reflect.Interface:
+		size := int64(v.Type().Size())
+		if !v.IsNil() {
+			size += estimateSize(v.Elem(), depth+1)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateSize(v.Field(i), depth+1)
+		}
+		// 字段之间的对齐填充
+		if padded := int64(v.Type().Size()); padded > size {
+			return padded
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
+
+// estimateElems 元素不包含引用时直接按大小计算
+func estimateElems(v reflect.Value, depth int) int64 {
+	elem := v.Type().Elem()
+	switch elem.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int64(v.Len()) * int64(elem.Size())
+	}
+
+	var size int64
+	for i := 0; i < v.Len(); i++ {
+		size += estimateSize(v.Index(i), depth+1)
+	}
+	return size
+}
+
+// SetMaxBytes limits the total weight of the values, as computed by weigher
+// or DefaultWeigher when weigher is nil. When a Set pushes the total over
+// maxBytes, entries are evicted from the heaviest partition until it fits,
+// and watchers receive EventEvict. Each eviction samples a few hash chains
+// of that partition and picks the entry read or written least recently, so
+// hot entries stay in memory. With EnableDiskTier, the entries are spilled
+// to disk instead. Values must not change size after Set.
+// A maxBytes of 0 or less removes the limit.
+func (m *concurrentMap) SetMaxBytes(maxBytes int64, weigher Weigher) {
+	if weigher == nil {
+		weigher = DefaultWeigher
+	}
+
+	m.mu.Lock()
+	if maxBytes <= 0 {
+		m.weigher = nil
+		atomic.StoreInt64(&m.maxBytes, 0)
+		atomic.StoreInt64(&m.bytes, 0)
+		m.mu.Unlock()
+		return
+	}
+
+	// 重新计算已有值的权重
+	var total int64
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			var weight int64
+			p.rangeData(func(data *innerSlice) bool {
+				weight += weigher(data.key, m.getValue(data.Value))
+				return true
+			})
+			atomic.StoreInt64(&p.weight, weight)
+			total += weight
+		}
+	}
+	m.weigher = weigher
+	atomic.StoreInt64(&m.maxBytes, maxBytes)
+	atomic.StoreInt64(&m.bytes, total)
+	m.mu.Unlock()
+
+	m.evict()
+}
+
+// MemoryUsage returns the total weight of the values accounted since
+// SetMaxBytes, or 0 when no limit is set.
+func (m *concurrentMap) MemoryUsage() int64 {
+	return atomic.LoadInt64(&m.bytes)
+}
+
+func (m *concurrentMap) addWeight(p *partition, w int64) {
+	atomic.AddInt64(&p.weight, w)
+	atomic.AddInt64(&m.bytes, w)
+}
+
+// evictSamples 每次淘汰时比较的链表数量
+const evictSamples = 5
+
+// touch 记录 data 被访问，只在 SetMaxBytes 之后记录。值没有变化时不写共享内存
+func (m *concurrentMap) touch(data *innerSlice) {
+	if data == nil || atomic.LoadInt64(&m.maxBytes) == 0 {
+		return
+	}
+	if epoch := atomic.LoadUint32(&m.epoch); atomic.LoadUint32(&data.epoch) != epoch {
+		atomic.StoreUint32(&data.epoch, epoch)
+	}
+}
+
+// coldest 在分区索引中从随机位置开始的 evictSamples 个链表中找到最久没有访问的值，
+// 返回它的 keyIndex、位置和链表中的前一个位置，调用方需持有写锁
+func (m *concurrentMap) coldest(p *partition) (keyIndex uint64, index, prev int) {
+	epoch := atomic.LoadUint32(&m.epoch)
+	index, prev = -1, -1
+	var maxAge int64 = -1
+	n := 0
+	p.index.rangeFrom(uint64(epoch)*0x9E3779B97F4A7C15, func(k uint64, i int) bool {
+		for j := -1; i >= 0; j, i = i, int(p.innerSlice[i].next) {
+			// epoch 回绕后差值仍然正确
+			if age := int64(epoch - atomic.LoadUint32(&p.innerSlice[i].epoch)); age > maxAge {
+				keyIndex, index, prev, maxAge = k, i, j, age
+			}
+		}
+		n++
+		return n < evictSamples
+	})
+	return keyIndex, index, prev
+}
+
+// evict 总权重超过上限时不断从最重的分区淘汰值，淘汰抽样中最久没有访问的值
+func (m *concurrentMap) evict() {
+	for atomic.LoadInt64(&m.bytes) > atomic.LoadInt64(&m.maxBytes) {
+		m.mu.RLock()
+		if m.weigher == nil {
+			m.mu.RUnlock()
+			return
+		}
+
+		var heaviest *partition
+		for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+			for _, p := range partitions {
+				if heaviest == nil || atomic.LoadInt64(&p.weight) > atomic.LoadInt64(&heaviest.weight) {
+					heaviest = p
+				}
+			}
+		}
+
+		p := heaviest
+		p.mu.Lock()
+		var keyIndex uint64
+		var data *innerSlice
+		spilled := false
+		migrated := p.migrated
+		if !migrated {
+			var index, prev int
+			if keyIndex, index, prev = m.coldest(p); index >= 0 {
+				data = p.innerSlice[index]
+				// 开启磁盘层时写入磁盘，附加索引中换成读取磁盘的占位值；写入失败时才真正淘汰
+				if m.disk != nil && m.disk.spill(keyIndex, data) == nil {
+					spilled = true
+					stub := spilledStub(keyIndex, data.key)
+					for _, idx := range m.indexes {
+						idx.set(stub)
+					}
+					m.unlinkLocked(p, keyIndex, index, prev)
+				} else {
+					m.removeLocked(p, keyIndex, index, prev)
+				}
+			}
+		}
+		if data != nil && !spilled && m.watchers.active() {
+			m.watchers.enqueue(keyIndex, Event{Type: EventEvict, Key: data.key, OldValue: m.getValue(data.Value)})
+		}
+		p.mu.Unlock()
+		m.mu.RUnlock()
+		m.watchers.deliver()
+
+		if spilled {
+			continue
+		}
+
+		if data == nil {
+			if migrated {
+				// 分区在加锁前被迁移，重新选择
+				continue
+			}
+			// 最重的分区也没有值可以淘汰
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDefaultWeigher(t *testing.T) {
+	type record struct {
+		Name string
+		Tags []string
+		Data map[string][]byte
+	}
+	small := DefaultWeigher("k", record{Name: "a"})
+	big := DefaultWeigher("k", record{
+		Name: "a",
+		Tags: []string{"x", "y"},
+		Data: map[string][]byte{"blob": make([]byte, 1<<20)},
+	})
+	if big-small < 1<<20 {
+		t.Errorf("weights --> %v, %v", small, big)
+	}
+	if w := DefaultWeigher(uint64(1), make([]byte, 100)); w < 100+entryOverhead || w > 200+entryOverhead {
+		t.Errorf("bytes weight --> %v", w)
+	}
+
+	// 循环引用不会无限递归
+	type node struct{ next *node }
+	n := &node{}
+	n.next = n
+	DefaultWeigher("k", n)
+}
+
+func TestMaxBytes(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(4)
+	weigher := func(key, value any) int64 {
+		return int64(len(value.([]byte)))
+	}
+	for i := 0; i < 10; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), make([]byte, 100))
+	}
+	events, cancel := mapData.Subscribe(WithBufferSize(100))
+	defer cancel()
+
+	mapData.SetMaxBytes(1000, weigher)
+	if mapData.MemoryUsage() != 1000 || mapData.Len() != 10 {
+		t.Errorf("usage --> %v, len --> %v", mapData.MemoryUsage(), mapData.Len())
+	}
+
+	// 覆盖写按差值计算
+	mapData.Set(StrKey("0"), make([]byte, 50))
+	if mapData.MemoryUsage() != 950 {
+		t.Errorf("usage after overwrite --> %v", mapData.MemoryUsage())
+	}
+
+	mapData.Set(StrKey("big"), make([]byte, 300))
+	if mapData.MemoryUsage() > 1000 || mapData.Len() >= 11 {
+		t.Errorf("usage after evict --> %v, len --> %v", mapData.MemoryUsage(), mapData.Len())
+	}
+	evicted := 0
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventEvict {
+			evicted++
+		}
+	}
+	if evicted != 11-mapData.Len() {
+		t.Errorf("evict events --> %v, len --> %v", evicted, mapData.Len())
+	}
+
+	mapData.Delete(StrKey("big"))
+	var sum int64
+	mapData.Range(func(key, value any) bool {
+		sum += int64(len(value.([]byte)))
+		return true
+	})
+	if mapData.MemoryUsage() != sum {
+		t.Errorf("usage --> %v, want %v", mapData.MemoryUsage(), sum)
+	}
+
+	mapData.SetMaxBytes(0, nil)
+	mapData.Set(StrKey("huge"), make([]byte, 5000))
+	if mapData.MemoryUsage() != 0 {
+		t.Errorf("usage without limit --> %v", mapData.MemoryUsage())
+	}
+}
+
+func TestMaxBytesGoroutine(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(2)
+	mapData.SetResizePolicy(1, 4)
+	mapData.SetMaxBytes(100000, nil)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				mapData.Set(I64Key(int64(g*2000+i)), make([]byte, i%100))
+				if i%3 == 0 {
+					mapData.Delete(I64Key(int64(g*2000 + i - 1)))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var sum int64
+	mapData.Range(func(key, value any) bool {
+		sum += DefaultWeigher(key, value)
+		return true
+	})
+	if mapData.MemoryUsage() != sum || sum > 100000 {
+		t.Errorf("usage --> %v, want %v", mapData.MemoryUsage(), sum)
+	}
+}
+
+func TestMaxBytesKeepsHotEntries(t *testing.T) {
+	for _, backend := range []PartitionBackend{BuiltinMapBackend, SwissTableBackend, CuckooBackend} {
+		for _, lockFree := range []bool{false, true} {
+			mapData := CreateConcurrentSliceMapWithBackend(1, backend)
+			if lockFree {
+				mapData.EnableLockFreeReads()
+			}
+			mapData.SetMaxBytes(10, func(key, value any) int64 { return 1 })
+
+			// 每写入一个新值读一次 hot，hot 总是最近访问的值，不会被淘汰
+			mapData.Set(StrKey("hot"), 0)
+			for i := 0; i < 200; i++ {
+				mapData.Set(I64Key(int64(i)), i)
+				if _, ok := mapData.Get(StrKey("hot")); !ok {
+					t.Fatalf("backend %v, lock-free %v: hot key evicted after %d sets", backend, lockFree, i+1)
+				}
+			}
+			if mapData.Len() != 10 {
+				t.Errorf("len --> %v, want 10", mapData.Len())
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package HighPerformanceMap
+
+import (
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// multiMap 使用 concurrentMap 的分区保存 key，innerSlice.Value 指向 multiValues
+type multiMap struct {
+	m      *concurrentMap
+	values int64 // 所有 key 的值的数量，原子操作
+}
+
+const multiInline = 4
+
+// multiValues 按写入顺序保存一个 key 的值，前 multiInline 个值和 key 一起分配，
+// 更多的值才放到 more 中
+type multiValues struct {
+	n      int
+	inline [multiInline]any
+	more   []any
+}
+
+func (l *multiValues) at(i int) any {
+	if i < multiInline {
+		return l.inline[i]
+	}
+	return l.more[i-multiInline]
+}
+
+func (l *multiValues) set(i int, v any) {
+	if i < multiInline {
+		l.inline[i] = v
+	} else {
+		l.more[i-multiInline] = v
+	}
+}
+
+func (l *multiValues) append(v any) {
+	if l.n < multiInline {
+		l.inline[l.n] = v
+	} else {
+		l.more = append(l.more, v)
+	}
+	l.n++
+}
+
+// removeAt 删除第 i 个值，后面的值向前移动
+func (l *multiValues) removeAt(i int) {
+	for ; i < l.n-1; i++ {
+		l.set(i, l.at(i+1))
+	}
+	l.n--
+	if l.n < multiInline {
+		l.inline[l.n] = nil
+	} else {
+		l.more[l.n-multiInline] = nil
+		l.more = l.more[:l.n-multiInline]
+	}
+}
+
+// CreateMultiMap creates a map that keeps every value put under a key, in
+// the order they were put.
+func CreateMultiMap(lenOfBucket int) *multiMap {
+	return &multiMap{m: CreateConcurrentSliceMap(lenOfBucket)}
+}
+
+func multiValuesOf(data *innerSlice) *multiValues {
+	return (*multiValues)(data.Value)
+}
+
+// Put adds v to the values of key.
+func (mm *multiMap) Put(key Partitionable, v any) {
+	keyIndex := key.PartitionKey()
+	m := mm.m
+
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, true)
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		multiValuesOf(p.innerSlice[index]).append(v)
+	} else {
+		l := &multiValues{}
+		l.append(v)
+		p.insert(keyIndex, &innerSlice{key: key.Value(), Value: unsafe.Pointer(l)})
+		atomic.AddInt64(&m.count, 1)
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	atomic.AddInt64(&mm.values, 1)
+	m.rehash()
+}
+
+// view 在读锁下调用 f，key 不存在时不调用
+func (mm *multiMap) view(key Partitionable, f func(l *multiValues)) {
+	keyIndex := key.PartitionKey()
+	m := mm.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(keyIndex, false)
+	defer p.mu.RUnlock()
+
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		f(multiValuesOf(p.innerSlice[index]))
+	}
+}
+
+// GetAll returns a copy of the values of key, or nil if there are none.
+func (mm *multiMap) GetAll(key Partitionable) []any {
+	var values []any
+	mm.view(key, func(l *multiValues) {
+		values = make([]any, l.n)
+		for i := range values {
+			values[i] = l.at(i)
+		}
+	})
+	return values
+}
+
+// Count returns the number of values of key.
+func (mm *multiMap) Count(key Partitionable) int {
+	n := 0
+	mm.view(key, func(l *multiValues) {
+		n = l.n
+	})
+	return n
+}
+
+// update 在写锁下修改 key 的值，f 返回删除的值的数量，值为空时删除 key
+func (mm *multiMap) update(key Partitionable, f func(l *multiValues) int) int {
+	removed := mm.updateLocked(key, f)
+	if removed > 0 {
+		atomic.AddInt64(&mm.values, -int64(removed))
+		mm.m.rehash()
+	}
+	return removed
+}
+
+// updateLocked 加锁调用 f，f 中的 panic 不会让锁一直被持有
+func (mm *multiMap) updateLocked(key Partitionable, f func(l *multiValues) int) int {
+	keyIndex := key.PartitionKey()
+	m := mm.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(keyIndex, true)
+	defer p.mu.Unlock()
+
+	index, prev, ok := p.lookup(keyIndex, key)
+	if !ok {
+		return 0
+	}
+	l := multiValuesOf(p.innerSlice[index])
+	removed := f(l)
+	if l.n == 0 {
+		p.unlink(keyIndex, index, prev)
+		atomic.AddInt64(&m.count, -1)
+	}
+	return removed
+}
+
+// valueEqual 比较两个值，类型不同或不能比较时返回 false，不会 panic
+func valueEqual(a, b any) (equal bool) {
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) {
+		return false
+	}
+	if t == nil {
+		return true
+	}
+	if !t.Comparable() {
+		return false
+	}
+	// 可比较的结构体、数组中的接口字段可能保存不能比较的值
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+	return a == b
+}
+
+// RemoveValue removes the first value of key equal to v and reports whether
+// one was found. The key is removed with its last value. Values that cannot
+// be compared with ==, such as slices and maps, never match; use
+// RemoveValueFunc for them.
+func (mm *multiMap) RemoveValue(key Partitionable, v any) bool {
+	return mm.RemoveValueFunc(key, func(value any) bool {
+		return valueEqual(value, v)
+	})
+}
+
+// RemoveValueFunc removes the first value of key for which match returns true
+// and reports whether one was found. match runs while the partition of the
+// key is write-locked.
+func (mm *multiMap) RemoveValueFunc(key Partitionable, match func(value any) bool) bool {
+	return mm.update(key, func(l *multiValues) int {
+		for i := 0; i < l.n; i++ {
+			if match(l.at(i)) {
+				l.removeAt(i)
+				return 1
+			}
+		}
+		return 0
+	}) > 0
+}
+
+// RemoveAll removes key with all of its values and returns how many values
+// were removed.
+func (mm *multiMap) RemoveAll(key Partitionable) int {
+	return mm.update(key, func(l *multiValues) int {
+		n := l.n
+		*l = multiValues{}
+		return n
+	})
+}
+
+// Len returns the number of keys.
+func (mm *multiMap) Len() int {
+	return mm.m.Len()
+}
+
+// ValueLen returns the number of values of all keys.
+func (mm *multiMap) ValueLen() int {
+	return int(atomic.LoadInt64(&mm.values))
+}
+
+// Range calls f for every value of every key until f returns false. f runs
+// while the partition of the key is read-locked.
+func (mm *multiMap) Range(f func(key, value any) bool) {
+	mm.m.rangeData(func(data *innerSlice) bool {
+		l := multiValuesOf(data)
+		for i := 0; i < l.n; i++ {
+			if !f(data.key, l.at(i)) {
+				return false
+			}
+		}
+		return true
+	})
+}
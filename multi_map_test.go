@@ -0,0 +1,101 @@
+package HighPerformanceMap
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMultiMap(t *testing.T) {
+	mm := CreateMultiMap(4)
+	for i := 0; i < 10; i++ {
+		mm.Put(StrKey("user"), i)
+	}
+	mm.Put(StrKey("other"), "x")
+
+	want := []any{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if values := mm.GetAll(StrKey("user")); !reflect.DeepEqual(values, want) {
+		t.Errorf("get all --> %v", values)
+	}
+	if !mm.RemoveValue(StrKey("user"), 2) || !mm.RemoveValue(StrKey("user"), 7) || mm.RemoveValue(StrKey("user"), 7) {
+		t.Error("remove value")
+	}
+	want = []any{0, 1, 3, 4, 5, 6, 8, 9}
+	if values := mm.GetAll(StrKey("user")); !reflect.DeepEqual(values, want) || mm.Count(StrKey("user")) != 8 {
+		t.Errorf("get all after remove --> %v", values)
+	}
+	if mm.Len() != 2 || mm.ValueLen() != 9 {
+		t.Errorf("len --> %v, value len --> %v", mm.Len(), mm.ValueLen())
+	}
+
+	n := 0
+	mm.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 9 {
+		t.Errorf("range --> %v", n)
+	}
+
+	if n := mm.RemoveAll(StrKey("user")); n != 8 {
+		t.Errorf("remove all --> %v", n)
+	}
+	if mm.RemoveValue(StrKey("other"), "x"); mm.Len() != 0 || mm.ValueLen() != 0 || mm.GetAll(StrKey("other")) != nil {
+		t.Errorf("len after remove --> %v, %v", mm.Len(), mm.ValueLen())
+	}
+}
+
+func TestMultiMapGoroutine(t *testing.T) {
+	mm := CreateMultiMap(4)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				mm.Put(I64Key(int64(i%10)), g*1000+i)
+				mm.GetAll(I64Key(int64(i % 10)))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		if n := mm.Count(I64Key(int64(i))); n != 800 {
+			t.Errorf("count %v --> %v", i, n)
+		}
+	}
+}
+
+func TestMultiMapUncomparableValues(t *testing.T) {
+	mm := CreateMultiMap(4)
+	type pair struct{ a, b any }
+	mm.Put(StrKey("k"), []byte("x"))
+	mm.Put(StrKey("k"), pair{1, []int{2}})
+	mm.Put(StrKey("k"), 3)
+
+	if mm.RemoveValue(StrKey("k"), []byte("x")) || mm.RemoveValue(StrKey("k"), pair{1, []int{2}}) {
+		t.Error("uncomparable value removed")
+	}
+	if !mm.RemoveValue(StrKey("k"), 3) {
+		t.Error("remove 3 failed")
+	}
+	if !mm.RemoveValueFunc(StrKey("k"), func(value any) bool {
+		b, ok := value.([]byte)
+		return ok && string(b) == "x"
+	}) {
+		t.Error("remove []byte failed")
+	}
+
+	// match 中的 panic 不会让分区一直被锁住
+	func() {
+		defer func() { recover() }()
+		mm.RemoveValueFunc(StrKey("k"), func(value any) bool {
+			panic("match")
+		})
+	}()
+	mm.Put(StrKey("k"), 4)
+	if n := mm.Count(StrKey("k")); n != 2 {
+		t.Errorf("count --> %v", n)
+	}
+}
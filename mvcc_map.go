@@ -0,0 +1,301 @@
+package HighPerformanceMap
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrVersionReclaimed is returned by GetAt when the versions needed to read
+// the key at the requested version have been reclaimed.
+var ErrVersionReclaimed = errors.New("HighPerformanceMap: version has been reclaimed")
+
+// mvccMap 每次写入产生一个新版本。key 的当前版本在分区索引的链表中，旧版本保存在同一分区
+// 的其它位置，由 mvccVersion.older 连接，不在索引中；不再被读取的旧版本放回分区的 free
+// 中复用。旧版本的位置在迁移分区时不会保留，所以 mvccMap 的分区数量固定
+type mvccMap struct {
+	m       *concurrentMap
+	version uint64 // 最后分配的版本号，原子操作
+	live    int64  // 当前版本未被删除的 key 的数量，原子操作
+
+	// 写入分配版本号并修改分区期间持有读锁，读取一致的版本号时持有写锁，
+	// 保证快照的版本号之前的写入都已完成
+	commitMu sync.RWMutex
+	readers  map[uint64]int // 打开的快照的版本号 → 数量，由 commitMu 保护
+	active   []uint64       // readers 中的版本号，从小到大，由 commitMu 保护
+}
+
+// mvccVersion key 的一个版本，innerSlice.Value 指向它
+type mvccVersion struct {
+	version   uint64
+	value     any
+	deleted   bool // 删除产生的版本
+	reclaimed bool // 更早的版本已被回收
+	older     int  // 上一个版本在分区 innerSlice 中的位置，-1 表示没有
+}
+
+// CreateMVCCMap creates a map keeping several versions of each key. Every
+// write is stamped with a new version, and a Snapshot reads the keys as they
+// were at its version while writers continue.
+func CreateMVCCMap(lenOfBucket int) *mvccMap {
+	return &mvccMap{m: CreateConcurrentSliceMap(lenOfBucket), readers: make(map[uint64]int)}
+}
+
+func versionOf(data *innerSlice) *mvccVersion {
+	return (*mvccVersion)(data.Value)
+}
+
+// write 写入新版本，deleted 为 true 时写入删除版本，返回新版本号和 key 写入前是否存在
+func (mm *mvccMap) write(key Partitionable, v any, deleted bool) (uint64, bool) {
+	keyIndex := key.PartitionKey()
+	m := mm.m
+
+	mm.commitMu.RLock()
+	defer mm.commitMu.RUnlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(keyIndex, true)
+	defer p.mu.Unlock()
+
+	index, _, ok := p.lookup(keyIndex, key)
+	existed := ok && !versionOf(p.innerSlice[index]).deleted
+	if deleted && !existed {
+		return 0, false
+	}
+
+	ver := atomic.AddUint64(&mm.version, 1)
+	if ok {
+		// 当前版本移到新的位置，索引中的位置保存新版本
+		data := p.innerSlice[index]
+		older := p.allocSlot(&innerSlice{key: data.key, Value: data.Value, next: -1})
+		data.Value = unsafe.Pointer(&mvccVersion{version: ver, value: v, deleted: deleted, older: older})
+		mm.prune(p, index)
+	} else {
+		p.insert(keyIndex, &innerSlice{
+			key:   key.Value(),
+			Value: unsafe.Pointer(&mvccVersion{version: ver, value: v, older: -1}),
+		})
+		atomic.AddInt64(&m.count, 1)
+	}
+
+	switch {
+	case deleted:
+		atomic.AddInt64(&mm.live, -1)
+	case !existed:
+		atomic.AddInt64(&mm.live, 1)
+	}
+	return ver, existed
+}
+
+// horizon 返回最早打开的快照的版本号，没有快照时为最新的版本号。只有读取更早的版本
+// 需要的版本可以回收，调用方持有 commitMu 的读锁
+func (mm *mvccMap) horizon() uint64 {
+	if len(mm.active) > 0 {
+		return mm.active[0]
+	}
+	return atomic.LoadUint64(&mm.version)
+}
+
+// prune 保留版本号大于 horizon 的版本和 horizon 时可见的版本，更早的版本的位置放回 free。
+// 被删除的 key 的旧版本回收后留下删除版本作为墓碑，GetAt 据此区分已回收的版本和从未写入的 key。
+// 返回释放的版本数量，调用方持有 commitMu 的读锁和分区的写锁
+func (mm *mvccMap) prune(p *partition, index int) int {
+	horizon := mm.horizon()
+	head := versionOf(p.innerSlice[index])
+	kept := head
+	for kept.version > horizon && kept.older >= 0 {
+		kept = versionOf(p.innerSlice[kept.older])
+	}
+
+	freed := 0
+	for i := kept.older; i >= 0; {
+		next := versionOf(p.innerSlice[i]).older
+		p.innerSlice[i] = nil
+		p.free = append(p.free, i)
+		freed++
+		i = next
+	}
+	if freed > 0 {
+		kept.older, kept.reclaimed = -1, true
+	}
+	return freed
+}
+
+// Set writes v as a new version of key and returns the version.
+func (mm *mvccMap) Set(key Partitionable, v any) uint64 {
+	ver, _ := mm.write(key, v, false)
+	return ver
+}
+
+// Delete writes a deletion of key as a new version and reports whether key
+// existed. Snapshots older than the deletion still see the key.
+func (mm *mvccMap) Delete(key Partitionable) bool {
+	_, existed := mm.write(key, nil, true)
+	return existed
+}
+
+// Get returns the latest version of key.
+func (mm *mvccMap) Get(key Partitionable) (any, bool) {
+	v, ok, _ := mm.GetAt(key, math.MaxUint64)
+	return v, ok
+}
+
+// GetAt returns the value key had at version. Every version at or after the
+// oldest open Snapshot can be read; older versions are reclaimed by writes
+// and Collect, and reading them returns ErrVersionReclaimed instead of
+// reporting the key as missing.
+func (mm *mvccMap) GetAt(key Partitionable, version uint64) (any, bool, error) {
+	keyIndex := key.PartitionKey()
+	m := mm.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := m.lockPartition(keyIndex, false)
+	defer p.mu.RUnlock()
+
+	index, _, ok := p.lookup(keyIndex, key)
+	if !ok {
+		return nil, false, nil
+	}
+	return mm.visible(p, versionOf(p.innerSlice[index]), version)
+}
+
+// visible 沿版本链找到 version 时可见的值，调用方持有分区的锁
+func (mm *mvccMap) visible(p *partition, v *mvccVersion, version uint64) (any, bool, error) {
+	for v.version > version {
+		if v.older < 0 {
+			if v.reclaimed {
+				return nil, false, ErrVersionReclaimed
+			}
+			return nil, false, nil
+		}
+		v = versionOf(p.innerSlice[v.older])
+	}
+	if v.deleted {
+		return nil, false, nil
+	}
+	return v.value, true, nil
+}
+
+// CurrentVersion returns the latest version whose write has completed, as
+// well as every write before it.
+func (mm *mvccMap) CurrentVersion() uint64 {
+	mm.commitMu.Lock()
+	defer mm.commitMu.Unlock()
+	return atomic.LoadUint64(&mm.version)
+}
+
+// Len returns the number of keys whose latest version is not a deletion.
+func (mm *mvccMap) Len() int {
+	return int(atomic.LoadInt64(&mm.live))
+}
+
+// Range calls f for the latest version of every key until f returns false.
+// f runs while the partition of the key is read-locked.
+func (mm *mvccMap) Range(f func(key, value any) bool) {
+	mm.rangeAt(math.MaxUint64, f)
+}
+
+func (mm *mvccMap) rangeAt(version uint64, f func(key, value any) bool) {
+	m := mm.m
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, p := range m.partitions {
+		ok := true
+		p.mu.RLock()
+		p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+			for ; ok && index >= 0; index = int(p.innerSlice[index].next) {
+				data := p.innerSlice[index]
+				// 快照的版本不早于 horizon，不会读到已回收的版本
+				if v, found, _ := mm.visible(p, versionOf(data), version); found {
+					ok = f(data.key, v)
+				}
+			}
+			return ok
+		})
+		p.mu.RUnlock()
+		if !ok {
+			return
+		}
+	}
+}
+
+// Collect reclaims the versions older than what the oldest open Snapshot
+// reads and returns how many versions were freed. Writes already reclaim the
+// old versions of the key they write. A deleted key keeps its deletion as a
+// tombstone so that GetAt reports its reclaimed versions; setting the key
+// again reuses it.
+func (mm *mvccMap) Collect() int {
+	m := mm.m
+	mm.commitMu.RLock()
+	defer mm.commitMu.RUnlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	freed := 0
+	for _, p := range m.partitions {
+		p.mu.Lock()
+		// prune 只回收旧版本，不修改索引中的链表
+		p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+			for ; index >= 0; index = int(p.innerSlice[index].next) {
+				freed += mm.prune(p, index)
+			}
+			return true
+		})
+		p.mu.Unlock()
+	}
+	return freed
+}
+
+// mvccSnapshot 打开时的版本号之前的写入都已完成，关闭之前这些版本不会被回收
+type mvccSnapshot struct {
+	mm      *mvccMap
+	version uint64
+	once    sync.Once
+}
+
+// Snapshot opens a reader handle at the current version. Close must be
+// called so the versions it reads can be reclaimed.
+func (mm *mvccMap) Snapshot() *mvccSnapshot {
+	mm.commitMu.Lock()
+	defer mm.commitMu.Unlock()
+
+	ver := atomic.LoadUint64(&mm.version)
+	if mm.readers[ver]++; mm.readers[ver] == 1 {
+		// 新的快照的版本号总是最大的
+		mm.active = append(mm.active, ver)
+	}
+	return &mvccSnapshot{mm: mm, version: ver}
+}
+
+func (s *mvccSnapshot) Version() uint64 {
+	return s.version
+}
+
+func (s *mvccSnapshot) Get(key Partitionable) (any, bool) {
+	v, ok, _ := s.mm.GetAt(key, s.version)
+	return v, ok
+}
+
+// Range calls f for every key as it was at the snapshot's version.
+func (s *mvccSnapshot) Range(f func(key, value any) bool) {
+	s.mm.rangeAt(s.version, f)
+}
+
+// Close releases the snapshot. It is safe to call more than once.
+func (s *mvccSnapshot) Close() {
+	s.once.Do(func() {
+		mm := s.mm
+		mm.commitMu.Lock()
+		defer mm.commitMu.Unlock()
+
+		if mm.readers[s.version]--; mm.readers[s.version] == 0 {
+			delete(mm.readers, s.version)
+			i := sort.Search(len(mm.active), func(i int) bool { return mm.active[i] >= s.version })
+			mm.active = append(mm.active[:i], mm.active[i+1:]...)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMVCCVersions(t *testing.T) {
+	mm := CreateMVCCMap(7)
+	v1 := mm.Set(StrKey("Hello"), 1)
+	v2 := mm.Set(StrKey("World"), 2)
+	if v1 != 1 || v2 != 2 || mm.CurrentVersion() != 2 {
+		t.Fatalf("versions --> %v, %v, current --> %v", v1, v2, mm.CurrentVersion())
+	}
+
+	snap := mm.Snapshot()
+	defer snap.Close()
+	v3 := mm.Set(StrKey("Hello"), 3)
+	mm.Delete(StrKey("World"))
+
+	if v, ok := mm.Get(StrKey("Hello")); !ok || v != 3 {
+		t.Errorf("get Hello --> %v, %v", v, ok)
+	}
+	if _, ok := mm.Get(StrKey("World")); ok {
+		t.Error("deleted key found")
+	}
+	if v, ok := snap.Get(StrKey("Hello")); !ok || v != 1 {
+		t.Errorf("snapshot get Hello --> %v, %v, want 1", v, ok)
+	}
+	if v, ok := snap.Get(StrKey("World")); !ok || v != 2 {
+		t.Errorf("snapshot get World --> %v, %v, want 2", v, ok)
+	}
+	if _, ok, err := mm.GetAt(StrKey("Hello"), v1-1); ok || err != nil {
+		t.Errorf("get before the first version --> %v, %v", ok, err)
+	}
+	if v, _, _ := mm.GetAt(StrKey("Hello"), v3); v != 3 {
+		t.Errorf("get Hello at %v --> %v", v3, v)
+	}
+	if mm.Len() != 1 {
+		t.Errorf("len --> %v, want 1", mm.Len())
+	}
+
+	n := 0
+	snap.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 2 {
+		t.Errorf("snapshot range --> %v, want 2", n)
+	}
+	if mm.Delete(StrKey("World")) {
+		t.Error("Delete of deleted key reported true")
+	}
+}
+
+func TestMVCCGarbageCollection(t *testing.T) {
+	mm := CreateMVCCMap(1)
+	key := StrKey("Hello")
+
+	// 没有快照时旧版本立即回收，位置被复用
+	for i := 0; i < 100; i++ {
+		mm.Set(key, i)
+	}
+	p := mm.m.partitions[0]
+	if len(p.innerSlice) > 2 {
+		t.Errorf("slots without snapshots --> %v", len(p.innerSlice))
+	}
+
+	snap := mm.Snapshot()
+	for i := 100; i < 200; i++ {
+		mm.Set(key, i)
+	}
+	if v, _ := snap.Get(key); v != 99 {
+		t.Errorf("snapshot get --> %v, want 99", v)
+	}
+	// 快照之后的版本都保留，快照之前的版本在写入时已回收
+	if used := len(p.innerSlice) - len(p.free); used != 101 {
+		t.Errorf("slots with one snapshot --> %v, want 101", used)
+	}
+	if v, _, err := mm.GetAt(key, snap.Version()+50); v != 149 || err != nil {
+		t.Errorf("get after the snapshot --> %v, %v, want 149", v, err)
+	}
+
+	mm.Delete(key)
+	if mm.m.Len() != 1 {
+		t.Fatal("deleted key dropped while a snapshot reads it")
+	}
+	snap.Close()
+	if freed := mm.Collect(); freed != 101 {
+		t.Errorf("collected --> %v, want 101", freed)
+	}
+	// 只留下删除版本作为墓碑
+	if used := len(p.innerSlice) - len(p.free); mm.Len() != 0 || used != 1 {
+		t.Errorf("len --> %v, used slots --> %v", mm.Len(), used)
+	}
+	if _, _, err := mm.GetAt(key, snap.Version()); err != ErrVersionReclaimed {
+		t.Errorf("GetAt of a collected key --> %v", err)
+	}
+
+	// 墓碑在再次写入后作为旧版本回收
+	mm.Set(key, 1)
+	if used := len(p.innerSlice) - len(p.free); used != 1 {
+		t.Errorf("used slots after set --> %v", used)
+	}
+	if _, _, err := mm.GetAt(key, snap.Version()); err != ErrVersionReclaimed {
+		t.Errorf("GetAt before the tombstone --> %v", err)
+	}
+}
+
+func TestMVCCNeverSetKey(t *testing.T) {
+	mm := CreateMVCCMap(1)
+	mm.Set(StrKey("a"), 1)
+	mm.Delete(StrKey("a"))
+	mm.Collect()
+
+	// 只有真的回收了版本的 key 返回 ErrVersionReclaimed
+	if _, ok, err := mm.GetAt(StrKey("never"), 0); ok || err != nil {
+		t.Errorf("GetAt of a never set key --> %v, %v", ok, err)
+	}
+	if _, ok, err := mm.GetAt(StrKey("a"), 1); ok || err != ErrVersionReclaimed {
+		t.Errorf("GetAt of a deleted key --> %v, %v", ok, err)
+	}
+	if _, ok, err := mm.GetAt(StrKey("a"), 2); ok || err != nil {
+		t.Errorf("GetAt at the deletion --> %v, %v", ok, err)
+	}
+}
+
+func TestMVCCVersionsAfterOldestSnapshot(t *testing.T) {
+	mm := CreateMVCCMap(7)
+	key := StrKey("a")
+	snap := mm.Snapshot()
+	for i := 1; i <= 3; i++ {
+		mm.Set(key, i)
+	}
+
+	// 比最早的快照新的版本都能读到
+	for ver := uint64(1); ver <= 3; ver++ {
+		if v, ok, err := mm.GetAt(key, ver); !ok || err != nil || v != int(ver) {
+			t.Errorf("get a at %v --> %v, %v, %v", ver, v, ok, err)
+		}
+	}
+
+	snap.Close()
+	mm.Set(key, 4)
+	if _, ok, err := mm.GetAt(key, 2); ok || err != ErrVersionReclaimed {
+		t.Errorf("get reclaimed version --> %v, %v", ok, err)
+	}
+	if v, ok, err := mm.GetAt(key, 4); !ok || err != nil || v != 4 {
+		t.Errorf("get a at 4 --> %v, %v, %v", v, ok, err)
+	}
+	if _, ok, err := mm.GetAt(StrKey("b"), 2); ok || err != nil {
+		t.Errorf("get missing key --> %v, %v", ok, err)
+	}
+}
+
+// 写入方依次写 a、b，快照中的 b 不会比 a 新
+func TestMVCCSnapshotConsistency(t *testing.T) {
+	mm := CreateMVCCMap(7)
+	mm.Set(StrKey("a"), 0)
+	mm.Set(StrKey("b"), 0)
+
+	var stop int32
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; atomic.LoadInt32(&stop) == 0; i++ {
+			mm.Set(StrKey("a"), i)
+			mm.Set(StrKey("b"), i)
+		}
+	}()
+
+	for i := 0; i < 2000; i++ {
+		snap := mm.Snapshot()
+		a, _ := snap.Get(StrKey("a"))
+		b, _ := snap.Get(StrKey("b"))
+		snap.Close()
+		if b.(int) > a.(int) || a.(int)-b.(int) > 1 {
+			t.Fatalf("snapshot a --> %v, b --> %v", a, b)
+		}
+	}
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+}
@@ -0,0 +1,334 @@
+//go:build linux
+
+package HighPerformanceMap
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// ErrUnsupportedKey is returned by OffHeapMap when a key's Value cannot be
+// stored as bytes.
+var ErrUnsupportedKey = errors.New("HighPerformanceMap: key type cannot be stored off heap")
+
+// ErrValueTooLarge is returned by OffHeapMap.Set when an entry does not fit
+// in a partition's ring buffer.
+var ErrValueTooLarge = errors.New("HighPerformanceMap: entry is larger than the partition buffer")
+
+// offHeapMap 的值保存在 mmap 分配的匿名内存中，不在 Go 的堆上。每个分区是一个环形缓冲区，
+// 新的值追加在末尾，空间不足时从头部淘汰最早写入的值。索引只保存位置，不含指针，
+// GC 不需要扫描，GC 的开销与缓存大小无关
+type offHeapMap struct {
+	partitions  []*offHeapPartition
+	lenOfBucket int
+	count       int64 // 原子操作
+}
+
+// offHeapPartition 中的位置是单调增加的绝对位置，pos%len(buf) 为在 buf 中的偏移，
+// head 之前的位置已被覆盖
+type offHeapPartition struct {
+	mu    sync.RWMutex
+	buf   []byte            // mmap 分配，Close 时释放
+	index map[uint64]uint64 // hash → 链表头的位置
+	head  uint64            // 最早的仍然有效的位置
+	tail  uint64            // 下一个写入的位置
+}
+
+// 每条记录的头部：keyIndex、hash 相同的上一条记录的位置+1、key 长度、value 长度、标记
+const (
+	offHeapHeaderSize = 32
+	offHeapDeleted    = 1 // 已删除或已被覆盖
+	offHeapPadding    = 2 // 缓冲区末尾放不下记录，跳到开头
+)
+
+type offHeapHeader struct {
+	keyIndex uint64
+	next     uint64
+	keyLen   uint32
+	valueLen uint32
+	flags    uint32
+}
+
+// CreateOffHeapMap creates a map of []byte values stored in anonymous mmap
+// memory outside the Go heap, with bytesPerPartition bytes for each of the
+// lenOfBucket partitions. When a partition is full the oldest entries are
+// evicted. Close must be called to release the memory.
+func CreateOffHeapMap(lenOfBucket, bytesPerPartition int) (*offHeapMap, error) {
+	m := &offHeapMap{
+		partitions:  make([]*offHeapPartition, lenOfBucket),
+		lenOfBucket: lenOfBucket,
+	}
+	for i := range m.partitions {
+		buf, err := syscall.Mmap(-1, 0, bytesPerPartition, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.partitions[i] = &offHeapPartition{buf: buf, index: make(map[uint64]uint64)}
+	}
+	return m, nil
+}
+
+// Close releases the memory of every partition. The map must not be used
+// afterwards.
+func (m *offHeapMap) Close() error {
+	var err error
+	for _, p := range m.partitions {
+		if p == nil {
+			continue
+		}
+		p.mu.Lock()
+		if p.buf != nil {
+			if e := syscall.Munmap(p.buf); e != nil && err == nil {
+				err = e
+			}
+			p.buf, p.index = nil, nil
+		}
+		p.mu.Unlock()
+	}
+	return err
+}
+
+// encodeOffHeapKey 把 Partitionable.Value 编码为字节，用于比较 hash 相同的 key
+func encodeOffHeapKey(dst []byte, v any) ([]byte, bool) {
+	switch k := v.(type) {
+	case string:
+		return append(append(dst, 's'), k...), true
+	case uint64:
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], k)
+		return append(append(dst, 'u'), b[:]...), true
+	case int32:
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(k))
+		return append(append(dst, 'i'), b[:]...), true
+	case [16]byte:
+		return append(append(dst, 'g'), k[:]...), true
+	case [2]any:
+		dst = append(dst, 'p', 0, 0, 0, 0)
+		start := len(dst)
+		dst, ok := encodeOffHeapKey(dst, k[0])
+		if !ok {
+			return nil, false
+		}
+		binary.LittleEndian.PutUint32(dst[start-4:], uint32(len(dst)-start))
+		return encodeOffHeapKey(dst, k[1])
+	}
+	return nil, false
+}
+
+func (m *offHeapMap) partition(key Partitionable) (*offHeapPartition, uint64, []byte, error) {
+	var buf [64]byte
+	k, ok := encodeOffHeapKey(buf[:0], key.Value())
+	if !ok {
+		return nil, 0, nil, ErrUnsupportedKey
+	}
+	keyIndex := key.PartitionKey()
+	return m.partitions[keyIndex%uint64(m.lenOfBucket)], keyIndex, k, nil
+}
+
+func (p *offHeapPartition) readHeader(pos uint64) offHeapHeader {
+	b := p.buf[pos%uint64(len(p.buf)):]
+	return offHeapHeader{
+		keyIndex: binary.LittleEndian.Uint64(b),
+		next:     binary.LittleEndian.Uint64(b[8:]),
+		keyLen:   binary.LittleEndian.Uint32(b[16:]),
+		valueLen: binary.LittleEndian.Uint32(b[20:]),
+		flags:    binary.LittleEndian.Uint32(b[24:]),
+	}
+}
+
+func (p *offHeapPartition) writeHeader(pos uint64, h offHeapHeader) {
+	b := p.buf[pos%uint64(len(p.buf)):]
+	binary.LittleEndian.PutUint64(b, h.keyIndex)
+	binary.LittleEndian.PutUint64(b[8:], h.next)
+	binary.LittleEndian.PutUint32(b[16:], h.keyLen)
+	binary.LittleEndian.PutUint32(b[20:], h.valueLen)
+	binary.LittleEndian.PutUint32(b[24:], h.flags)
+}
+
+func (p *offHeapPartition) setFlags(pos uint64, flags uint32) {
+	binary.LittleEndian.PutUint32(p.buf[pos%uint64(len(p.buf))+24:], flags)
+}
+
+// record 返回记录中的 key 和 value，直接引用 mmap 的内存
+func (p *offHeapPartition) record(pos uint64, h offHeapHeader) (key, value []byte) {
+	start := pos%uint64(len(p.buf)) + offHeapHeaderSize
+	key = p.buf[start : start+uint64(h.keyLen)]
+	value = p.buf[start+uint64(h.keyLen) : start+uint64(h.keyLen)+uint64(h.valueLen)]
+	return key, value
+}
+
+// valid 位置+1 的编码，0 表示没有
+func (p *offHeapPartition) valid(next uint64) bool {
+	return next != 0 && next-1 >= p.head
+}
+
+// lookup 沿 hash 相同的链表查找未删除的 key，返回记录的位置和头部
+func (p *offHeapPartition) lookup(keyIndex uint64, key []byte) (uint64, offHeapHeader, bool) {
+	next, ok := p.index[keyIndex]
+	for ok && p.valid(next) {
+		pos := next - 1
+		h := p.readHeader(pos)
+		if k, _ := p.record(pos, h); h.flags&offHeapDeleted == 0 && string(k) == string(key) {
+			return pos, h, true
+		}
+		next = h.next
+	}
+	return 0, offHeapHeader{}, false
+}
+
+// evictHead 淘汰头部的一条记录，返回是否淘汰了一个有效的 key
+func (p *offHeapPartition) evictHead() bool {
+	size := uint64(len(p.buf))
+	if rest := size - p.head%size; rest < offHeapHeaderSize {
+		p.head += rest
+		return false
+	}
+
+	h := p.readHeader(p.head)
+	if h.flags&offHeapPadding != 0 {
+		p.head += size - p.head%size
+		return false
+	}
+	pos := p.head
+	p.head += offHeapHeaderSize + uint64(h.keyLen) + uint64(h.valueLen)
+	if h.flags&offHeapDeleted != 0 {
+		return false
+	}
+	// 被淘汰的记录是链表头时，链表头改为上一条记录，其余情况链表会在 valid 处截断
+	if p.index[h.keyIndex] == pos+1 {
+		if p.valid(h.next) {
+			p.index[h.keyIndex] = h.next
+		} else {
+			delete(p.index, h.keyIndex)
+		}
+	}
+	return true
+}
+
+// append 追加一条记录，返回写入的位置和淘汰的有效 key 的数量
+func (p *offHeapPartition) append(h offHeapHeader, key, value []byte) (uint64, int) {
+	size := uint64(len(p.buf))
+	n := offHeapHeaderSize + uint64(len(key)) + uint64(len(value))
+	evicted := 0
+
+	// 末尾放不下时写入填充标记，从缓冲区开头写入
+	if rest := size - p.tail%size; rest < n {
+		for p.tail+rest-p.head > size {
+			if p.evictHead() {
+				evicted++
+			}
+		}
+		if rest >= offHeapHeaderSize {
+			p.writeHeader(p.tail, offHeapHeader{flags: offHeapPadding})
+		}
+		p.tail += rest
+	}
+	for p.tail+n-p.head > size {
+		if p.evictHead() {
+			evicted++
+		}
+	}
+
+	pos := p.tail
+	p.writeHeader(pos, h)
+	start := pos%size + offHeapHeaderSize
+	copy(p.buf[start:], key)
+	copy(p.buf[start+uint64(len(key)):], value)
+	p.tail += n
+	return pos, evicted
+}
+
+// Set stores a copy of value under key, evicting the oldest entries of the
+// partition when it is full.
+func (m *offHeapMap) Set(key Partitionable, value []byte) error {
+	p, keyIndex, k, err := m.partition(key)
+	if err != nil {
+		return err
+	}
+	if offHeapHeaderSize+len(k)+len(value) > len(p.buf) {
+		return ErrValueTooLarge
+	}
+
+	p.mu.Lock()
+	h := offHeapHeader{keyIndex: keyIndex, keyLen: uint32(len(k)), valueLen: uint32(len(value))}
+	oldPos, old, exists := p.lookup(keyIndex, k)
+	if exists {
+		p.setFlags(oldPos, old.flags|offHeapDeleted)
+	}
+	pos, evicted := p.append(h, k, value)
+	// 写入之后再读取链表头，链表头可能在追加时被淘汰
+	if next, ok := p.index[keyIndex]; ok && p.valid(next) {
+		h.next = next
+		if exists && next == oldPos+1 {
+			// 跳过被覆盖的旧记录
+			h.next = old.next
+		}
+		p.writeHeader(pos, h)
+	}
+	p.index[keyIndex] = pos + 1
+	p.mu.Unlock()
+
+	delta := int64(-evicted)
+	if !exists {
+		delta++
+	}
+	atomic.AddInt64(&m.count, delta)
+	return nil
+}
+
+// Get returns a copy of the value of key.
+func (m *offHeapMap) Get(key Partitionable) ([]byte, bool) {
+	var value []byte
+	ok := m.View(key, func(v []byte) {
+		value = append([]byte(nil), v...)
+	})
+	return value, ok
+}
+
+// View calls f with the value of key while the partition is read-locked,
+// without copying it. f must not keep value or modify it.
+func (m *offHeapMap) View(key Partitionable, f func(value []byte)) bool {
+	p, keyIndex, k, err := m.partition(key)
+	if err != nil {
+		return false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	pos, h, ok := p.lookup(keyIndex, k)
+	if ok {
+		_, value := p.record(pos, h)
+		f(value)
+	}
+	return ok
+}
+
+func (m *offHeapMap) Delete(key Partitionable) bool {
+	p, keyIndex, k, err := m.partition(key)
+	if err != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	pos, h, ok := p.lookup(keyIndex, k)
+	if ok {
+		p.setFlags(pos, h.flags|offHeapDeleted)
+		if p.index[keyIndex] == pos+1 && !p.valid(h.next) {
+			delete(p.index, keyIndex)
+		}
+	}
+	p.mu.Unlock()
+	if ok {
+		atomic.AddInt64(&m.count, -1)
+	}
+	return ok
+}
+
+func (m *offHeapMap) Len() int {
+	return int(atomic.LoadInt64(&m.count))
+}
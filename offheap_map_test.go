@@ -0,0 +1,136 @@
+//go:build linux
+
+package HighPerformanceMap
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestOffHeapMap(t *testing.T) {
+	m, err := CreateOffHeapMap(2, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := m.Set(StrKey(strconv.Itoa(i)), bytes.Repeat([]byte{byte(i)}, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Set(I64Key(-1), []byte("int"))
+	m.Set(PairKey(StrKey("a"), I64Key(1)), []byte("pair"))
+	m.Set(StrKey("3"), []byte("new"))
+	m.Delete(StrKey("4"))
+
+	if v, ok := m.Get(StrKey("3")); !ok || string(v) != "new" {
+		t.Errorf("get after overwrite --> %q, %v", v, ok)
+	}
+	if _, ok := m.Get(StrKey("4")); ok {
+		t.Error("deleted key found")
+	}
+	if v, ok := m.Get(StrKey("5")); !ok || !bytes.Equal(v, bytes.Repeat([]byte{5}, 10)) {
+		t.Errorf("get --> %v, %v", v, ok)
+	}
+	if v, _ := m.Get(PairKey(StrKey("a"), I64Key(1))); string(v) != "pair" {
+		t.Errorf("pair key --> %q", v)
+	}
+	if v, _ := m.Get(I64Key(-1)); string(v) != "int" || m.Len() != 11 {
+		t.Errorf("int key --> %q, len --> %v", v, m.Len())
+	}
+
+	if err := m.Set(StrKey("big"), make([]byte, 4096)); err != ErrValueTooLarge {
+		t.Errorf("too large --> %v", err)
+	}
+	if err := m.Set(PairKey(StrKey("a"), UUIDKey([16]byte{1})), nil); err != nil {
+		t.Errorf("uuid pair --> %v", err)
+	}
+}
+
+func TestOffHeapMapCollision(t *testing.T) {
+	m, _ := CreateOffHeapMap(1, 4096)
+	defer m.Close()
+
+	// hash 相同的 key 各自保存
+	m.Set(collideKey("a"), []byte("1"))
+	m.Set(collideKey("b"), []byte("2"))
+	m.Set(collideKey("a"), []byte("3"))
+	if v, _ := m.Get(collideKey("a")); string(v) != "3" {
+		t.Errorf("a --> %q", v)
+	}
+	if v, _ := m.Get(collideKey("b")); string(v) != "2" {
+		t.Errorf("b --> %q", v)
+	}
+	m.Delete(collideKey("a"))
+	if _, ok := m.Get(collideKey("a")); ok || m.Len() != 1 {
+		t.Errorf("len --> %v", m.Len())
+	}
+}
+
+func TestOffHeapMapEvict(t *testing.T) {
+	m, _ := CreateOffHeapMap(1, 1000)
+	defer m.Close()
+
+	// 每条记录 32+9+50 字节，写入 100 条后只保留最新的若干条
+	for i := 0; i < 100; i++ {
+		m.Set(I64Key(int64(i)), bytes.Repeat([]byte{byte(i)}, 50))
+	}
+	if m.Len() == 0 || m.Len() > 1000/91 {
+		t.Errorf("len --> %v", m.Len())
+	}
+	for i := 0; i < 100; i++ {
+		v, ok := m.Get(I64Key(int64(i)))
+		if i >= 100-m.Len() {
+			if !ok || v[0] != byte(i) || len(v) != 50 {
+				t.Fatalf("get %v --> %v, %v", i, v, ok)
+			}
+		} else if ok {
+			t.Fatalf("evicted key %v found", i)
+		}
+	}
+}
+
+func TestOffHeapMapGoroutine(t *testing.T) {
+	m, _ := CreateOffHeapMap(4, 1<<20)
+	defer m.Close()
+	wg := sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := StrKey(strconv.Itoa(g) + ":" + strconv.Itoa(i%50))
+				m.Set(key, []byte(strconv.Itoa(i)))
+				if v, ok := m.Get(key); !ok || string(v) != strconv.Itoa(i) {
+					t.Errorf("get --> %q, %v", v, ok)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if m.Len() != 8*50 {
+		t.Errorf("len --> %v", m.Len())
+	}
+}
+
+func TestOffHeapMapKeyTypes(t *testing.T) {
+	m, _ := CreateOffHeapMap(1, 4096)
+	defer m.Close()
+
+	// hash 相同、类型不同的 key 互不影响
+	keys := []Partitionable{I32Key(-7), I32Key(7), I64Key(7), PairKey(I32Key(7), StrKey("a"))}
+	for i, k := range keys {
+		if err := m.Set(k, []byte{byte(i)}); err != nil {
+			t.Fatalf("set %v --> %v", k.Value(), err)
+		}
+	}
+	for i, k := range keys {
+		if v, ok := m.Get(k); !ok || len(v) != 1 || v[0] != byte(i) {
+			t.Errorf("get %v --> %v, %v", k.Value(), v, ok)
+		}
+	}
+}
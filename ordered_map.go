@@ -0,0 +1,237 @@
+package HighPerformanceMap
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+const (
+	int64IndexShards = 32 // 跳表的数量，key 按取模分到各个跳表，写入只锁一个跳表
+	int64IndexBatch  = 64 // 遍历时每次加锁从一个跳表复制的最大数量
+)
+
+// orderedMap 在 concurrentMap 的基础上用跳表维护 int64 key 的顺序，
+// 值仍然保存在 map 的 innerSlice 中，只有 I64Key 的 key 参与排序
+type orderedMap struct {
+	*concurrentMap
+	index *int64Index
+}
+
+// int64Index 把 key 分到多个各自加锁的跳表中，不同跳表的写入互不阻塞，
+// 有序读取时合并各个跳表
+type int64Index struct {
+	shards [int64IndexShards]int64IndexShard
+}
+
+type int64IndexShard struct {
+	mu   sync.RWMutex
+	list *skipList
+}
+
+func (i *int64Index) shard(key int64) *int64IndexShard {
+	return &i.shards[uint64(key)%int64IndexShards]
+}
+
+func (i *int64Index) set(data *innerSlice) {
+	if k, ok := data.key.(uint64); ok {
+		s := i.shard(int64(k))
+		s.mu.Lock()
+		s.list.set(int64(k), data)
+		s.mu.Unlock()
+	}
+}
+
+func (i *int64Index) delete(key any) {
+	if k, ok := key.(uint64); ok {
+		s := i.shard(int64(k))
+		s.mu.Lock()
+		s.list.delete(int64(k))
+		s.mu.Unlock()
+	}
+}
+
+// CreateOrderedSliceMap creates a map keeping its I64Key keys in order.
+// Writers only serialize on the skiplist shard of their key; ordered reads
+// merge the shards and are not a point-in-time snapshot.
+func CreateOrderedSliceMap(lenOfBucket int) *orderedMap {
+	m := &orderedMap{
+		concurrentMap: CreateConcurrentSliceMap(lenOfBucket),
+		index:         &int64Index{},
+	}
+	for i := range m.index.shards {
+		m.index.shards[i].list = newSkipList()
+	}
+	m.indexes = append(m.indexes, m.index)
+	return m
+}
+
+type indexEntry struct {
+	key  int64
+	data *innerSlice
+}
+
+// indexCursor 按顺序读取一个跳表中 [lo, hi] 的 key，每次加读锁复制一批，
+// 复制的数量从 1 开始加倍到 int64IndexBatch，只取第一个值时不复制整批
+type indexCursor struct {
+	shard  *int64IndexShard
+	desc   bool
+	lo, hi int64 // 还未读取的范围
+	size   int
+	buf    []indexEntry
+	pos    int
+	done   bool // 范围内已经没有未复制的 key
+}
+
+func (c *indexCursor) fill() {
+	c.buf, c.pos = c.buf[:0], 0
+	if c.done {
+		return
+	}
+	if c.size = c.size*2 + 1; c.size > int64IndexBatch {
+		c.size = int64IndexBatch
+	}
+
+	c.shard.mu.RLock()
+	var x *skipListNode
+	if c.desc {
+		x = c.shard.list.floor(c.hi)
+	} else {
+		x = c.shard.list.ceiling(c.lo)
+	}
+	for x != nil && x.key >= c.lo && x.key <= c.hi && len(c.buf) < c.size {
+		c.buf = append(c.buf, indexEntry{x.key, x.data})
+		if c.desc {
+			x = x.prev
+		} else {
+			x = x.next[0]
+		}
+	}
+	c.shard.mu.RUnlock()
+
+	if len(c.buf) < c.size {
+		c.done = true
+		return
+	}
+	last := c.buf[len(c.buf)-1].key
+	switch {
+	case c.desc && last == math.MinInt64, !c.desc && last == math.MaxInt64:
+		c.done = true
+	case c.desc:
+		c.hi = last - 1
+	default:
+		c.lo = last + 1
+	}
+}
+
+func (c *indexCursor) peek() (indexEntry, bool) {
+	if c.pos == len(c.buf) {
+		c.fill()
+		if len(c.buf) == 0 {
+			return indexEntry{}, false
+		}
+	}
+	return c.buf[c.pos], true
+}
+
+// cursorHeap 按各个跳表下一个 key 排序的 cursor
+type cursorHeap struct {
+	cursors []*indexCursor
+	desc    bool
+}
+
+func (h *cursorHeap) Len() int { return len(h.cursors) }
+
+func (h *cursorHeap) Less(i, j int) bool {
+	a := h.cursors[i].buf[h.cursors[i].pos].key
+	b := h.cursors[j].buf[h.cursors[j].pos].key
+	if h.desc {
+		return a > b
+	}
+	return a < b
+}
+
+func (h *cursorHeap) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *cursorHeap) Push(x any) { h.cursors = append(h.cursors, x.(*indexCursor)) }
+
+func (h *cursorHeap) Pop() any {
+	c := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return c
+}
+
+// scan 合并所有跳表，按顺序对 [lo, hi] 中未过期的 key 调用 f，写入磁盘的 key 从磁盘读取，调用 f 时不持有任何锁
+func (m *orderedMap) scan(lo, hi int64, desc bool, f func(key int64, value any) bool) {
+	if lo > hi {
+		return
+	}
+	h := &cursorHeap{desc: desc}
+	for i := range m.index.shards {
+		c := &indexCursor{shard: &m.index.shards[i], desc: desc, lo: lo, hi: hi}
+		if _, ok := c.peek(); ok {
+			h.cursors = append(h.cursors, c)
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		c := h.cursors[0]
+		e := c.buf[c.pos]
+		c.pos++
+		if _, ok := c.peek(); ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+		if v, ok := m.indexedValue(e.data); ok && !f(e.key, v) {
+			return
+		}
+	}
+}
+
+func (m *orderedMap) first(lo, hi int64, desc bool) (int64, any, bool) {
+	var key int64
+	var value any
+	found := false
+	m.scan(lo, hi, desc, func(k int64, v any) bool {
+		key, value, found = k, v, true
+		return false
+	})
+	return key, value, found
+}
+
+// Ascend calls f for every int64 key in ascending order until f returns false.
+// f runs without any lock held and may write to the map; keys written during
+// the iteration may or may not be visited.
+func (m *orderedMap) Ascend(f func(key int64, value any) bool) {
+	m.scan(math.MinInt64, math.MaxInt64, false, f)
+}
+
+// Descend calls f for every int64 key in descending order until f returns false.
+func (m *orderedMap) Descend(f func(key int64, value any) bool) {
+	m.scan(math.MinInt64, math.MaxInt64, true, f)
+}
+
+// RangeBetween calls f in ascending order for the keys in [lo, hi].
+func (m *orderedMap) RangeBetween(lo, hi int64, f func(key int64, value any) bool) {
+	m.scan(lo, hi, false, f)
+}
+
+func (m *orderedMap) Min() (int64, any, bool) {
+	return m.first(math.MinInt64, math.MaxInt64, false)
+}
+
+func (m *orderedMap) Max() (int64, any, bool) {
+	return m.first(math.MinInt64, math.MaxInt64, true)
+}
+
+// Floor returns the greatest key less than or equal to key.
+func (m *orderedMap) Floor(key int64) (int64, any, bool) {
+	return m.first(math.MinInt64, key, true)
+}
+
+// Ceiling returns the least key greater than or equal to key.
+func (m *orderedMap) Ceiling(key int64) (int64, any, bool) {
+	return m.first(key, math.MaxInt64, false)
+}
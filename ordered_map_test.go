@@ -0,0 +1,195 @@
+package HighPerformanceMap
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestOrderedMapAscendDescend(t *testing.T) {
+	mapData := CreateOrderedSliceMap(99)
+	keys := rand.Perm(1000)
+	for _, k := range keys {
+		mapData.Set(I64Key(int64(k-500)), k-500)
+	}
+	for i := 0; i < 100; i++ {
+		mapData.Delete(I64Key(int64(keys[i] - 500)))
+	}
+	mapData.Set(I64Key(0), "zero")
+
+	var asc []int64
+	mapData.Ascend(func(key int64, value any) bool {
+		asc = append(asc, key)
+		return true
+	})
+	if len(asc) != mapData.Len() || !sort.SliceIsSorted(asc, func(i, j int) bool { return asc[i] < asc[j] }) {
+		t.Fatalf("ascend failed, len --> %v", len(asc))
+	}
+
+	i := len(asc)
+	mapData.Descend(func(key int64, value any) bool {
+		i--
+		if asc[i] != key {
+			t.Fatalf("descend got %v, want %v", key, asc[i])
+		}
+		if key == 0 && value.(string) != "zero" {
+			t.Errorf("value of 0 --> %v", value)
+		}
+		return true
+	})
+}
+
+func TestOrderedMapQueries(t *testing.T) {
+	mapData := CreateOrderedSliceMap(9)
+	if _, _, ok := mapData.Min(); ok {
+		t.Error("min of empty map")
+	}
+
+	for _, k := range []int64{-20, -10, 0, 10, 20, 30} {
+		mapData.Set(I64Key(k), k)
+	}
+	mapData.Set(StrKey("ignored"), 1)
+
+	var between []int64
+	mapData.RangeBetween(-10, 15, func(key int64, value any) bool {
+		between = append(between, value.(int64))
+		return true
+	})
+	if len(between) != 3 || between[0] != -10 || between[2] != 10 {
+		t.Errorf("range between --> %v", between)
+	}
+
+	if k, _, _ := mapData.Min(); k != -20 {
+		t.Errorf("min --> %v", k)
+	}
+	if k, _, _ := mapData.Max(); k != 30 {
+		t.Errorf("max --> %v", k)
+	}
+	if k, _, _ := mapData.Floor(15); k != 10 {
+		t.Errorf("floor --> %v", k)
+	}
+	if k, _, _ := mapData.Floor(20); k != 20 {
+		t.Errorf("floor --> %v", k)
+	}
+	if _, _, ok := mapData.Floor(-21); ok {
+		t.Error("floor below min")
+	}
+	if k, _, _ := mapData.Ceiling(-15); k != -10 {
+		t.Errorf("ceiling --> %v", k)
+	}
+	if _, _, ok := mapData.Ceiling(31); ok {
+		t.Error("ceiling above max")
+	}
+
+	mapData.Delete(I64Key(30))
+	if k, _, _ := mapData.Max(); k != 20 {
+		t.Errorf("max after delete --> %v", k)
+	}
+}
+
+func TestOrderedMapGoroutine(t *testing.T) {
+	mapData := CreateOrderedSliceMap(99)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(base int64) {
+			defer wg.Done()
+			for j := int64(0); j < 1000; j++ {
+				mapData.Set(I64Key(base*1000+j), j)
+				if j%2 == 0 {
+					mapData.Delete(I64Key(base*1000 + j))
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	n := 0
+	prev := int64(-1)
+	mapData.Ascend(func(key int64, value any) bool {
+		if key <= prev || key%2 == 0 {
+			t.Fatalf("unexpected key %v after %v", key, prev)
+		}
+		prev = key
+		n++
+		return true
+	})
+	if n != 5000 {
+		t.Errorf("len --> %v", n)
+	}
+}
+
+func TestOrderedMapWriteInCallback(t *testing.T) {
+	mapData := CreateOrderedSliceMap(9)
+	for k := int64(0); k < 200; k++ {
+		mapData.Set(I64Key(k), k)
+	}
+
+	// 回调中写入不会死锁
+	n := 0
+	mapData.Ascend(func(key int64, value any) bool {
+		mapData.Set(I64Key(key), value.(int64)*2)
+		mapData.Delete(I64Key(key + 1000))
+		n++
+		return true
+	})
+	if n != 200 {
+		t.Errorf("visited --> %v", n)
+	}
+	if v, _ := mapData.Get(I64Key(199)); v != int64(398) {
+		t.Errorf("199 --> %v", v)
+	}
+}
+
+func TestOrderedMapExtremeKeys(t *testing.T) {
+	mapData := CreateOrderedSliceMap(9)
+	for _, k := range []int64{math.MinInt64, -1, 0, math.MaxInt64} {
+		mapData.Set(I64Key(k), k)
+	}
+
+	if k, _, _ := mapData.Min(); k != math.MinInt64 {
+		t.Errorf("min --> %v", k)
+	}
+	if k, _, _ := mapData.Max(); k != math.MaxInt64 {
+		t.Errorf("max --> %v", k)
+	}
+	if k, _, _ := mapData.Floor(math.MaxInt64 - 1); k != 0 {
+		t.Errorf("floor --> %v", k)
+	}
+	var desc []int64
+	mapData.Descend(func(key int64, value any) bool {
+		desc = append(desc, key)
+		return true
+	})
+	if len(desc) != 4 || desc[0] != math.MaxInt64 || desc[3] != math.MinInt64 {
+		t.Errorf("descend --> %v", desc)
+	}
+}
+
+// 有序 map 的写入与普通 map 对比，写入只在 key 所在的跳表上串行
+func BenchmarkOrderedMapSet(b *testing.B) {
+	run := func(b *testing.B, mapData Map) {
+		var seq int64
+		var mu sync.Mutex
+		b.RunParallel(func(pb *testing.PB) {
+			mu.Lock()
+			seq++
+			base := seq << 32
+			mu.Unlock()
+			i := int64(0)
+			for pb.Next() {
+				mapData.Set(I64Key(base+i%100000), i)
+				i++
+			}
+		})
+	}
+	b.Run("plain", func(b *testing.B) {
+		run(b, CreateConcurrentSliceMap(99))
+	})
+	b.Run("ordered", func(b *testing.B) {
+		run(b, CreateOrderedSliceMap(99))
+	})
+}
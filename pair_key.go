@@ -0,0 +1,42 @@
+package HighPerformanceMap
+
+// mix64 是 splitmix64 的结尾混淆，让每一位输入影响全部输出
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// combineHash 与顺序有关，(a, b) 与 (b, a) 的结果不同
+func combineHash(a, b uint64) uint64 {
+	return mix64(mix64(a) + b)
+}
+
+// pairKey 组合两个 key，Value 为 [2]any{first.Value(), second.Value()}
+type pairKey struct {
+	key    uint64
+	first  Partitionable
+	second Partitionable
+}
+
+func (p *pairKey) PartitionKey() uint64 {
+	return p.key
+}
+
+func (p *pairKey) Value() any {
+	return [2]any{p.first.Value(), p.second.Value()}
+}
+
+func (p *pairKey) equal(stored any) bool {
+	v, ok := stored.([2]any)
+	return ok && keyEqual(p.first, v[0]) && keyEqual(p.second, v[1])
+}
+
+// PairKey builds a composite key such as (int64, string) from two keys.
+// Longer tuples can be built by nesting pairs.
+func PairKey(first, second Partitionable) *pairKey {
+	return &pairKey{combineHash(first.PartitionKey(), second.PartitionKey()), first, second}
+}
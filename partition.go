@@ -0,0 +1,197 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// partition 每个分区有自己的锁和保存值的切片，互不影响
+type partition struct {
+	mu         sync.RWMutex
+	index      slotIndex      // hash 对应链表头在 innerSlice 中的位置
+	free       []int          // 用户记录删除切片的位置
+	innerSlice []*innerSlice  // 用户记录所用的值的位置
+	migrated   bool           // 扩缩容时已整体迁移到新的分区表
+	stats      partitionStats // 只在自适应模式下统计
+	view       atomic.Value   // *partitionView，只在无锁读模式下发布
+	weight     int64          // 分区中值的权重之和，只在 SetMaxBytes 之后统计，原子操作
+}
+
+// PartitionBackend selects the hash index used inside each partition.
+type PartitionBackend int
+
+const (
+	BuiltinMapBackend PartitionBackend = iota // Go 内置的 map[uint64]int
+	SwissTableBackend                         // 控制字节分组的开放寻址表，位置直接存放在表中
+	CuckooBackend                             // 两个 hash 函数的 cuckoo hash，查找最多比较 8 个位置
+)
+
+// slotIndex 分区中 hash 到链表头位置的索引，调用方持有分区的锁
+type slotIndex interface {
+	get(keyIndex uint64) (int, bool)
+	set(keyIndex uint64, index int)
+	delete(keyIndex uint64)
+	len() int
+	rangeIndex(f func(keyIndex uint64, index int) bool)
+	rangeFrom(start uint64, f func(keyIndex uint64, index int) bool) // 从 start 决定的位置开始遍历
+}
+
+func newSlotIndex(backend PartitionBackend) slotIndex {
+	switch backend {
+	case SwissTableBackend:
+		return newSwissIndex()
+	case CuckooBackend:
+		return newCuckooIndex()
+	}
+	return mapIndex{}
+}
+
+func newPartitions(n int, backend PartitionBackend) []*partition {
+	partitions := make([]*partition, n)
+	for i := 0; i < n; i++ {
+		partitions[i] = &partition{index: newSlotIndex(backend)}
+	}
+	return partitions
+}
+
+// mapIndex 默认的 slotIndex
+type mapIndex map[uint64]int
+
+func (m mapIndex) get(keyIndex uint64) (int, bool) {
+	index, ok := m[keyIndex]
+	return index, ok
+}
+
+func (m mapIndex) set(keyIndex uint64, index int) {
+	m[keyIndex] = index
+}
+
+func (m mapIndex) delete(keyIndex uint64) {
+	delete(m, keyIndex)
+}
+
+func (m mapIndex) len() int {
+	return len(m)
+}
+
+func (m mapIndex) rangeIndex(f func(keyIndex uint64, index int) bool) {
+	for keyIndex, index := range m {
+		if !f(keyIndex, index) {
+			return
+		}
+	}
+}
+
+// rangeFrom 内置 map 的遍历顺序本身是随机的，忽略 start
+func (m mapIndex) rangeFrom(start uint64, f func(keyIndex uint64, index int) bool) {
+	m.rangeIndex(f)
+}
+
+func (p *partition) lock(write bool) {
+	if write {
+		p.mu.Lock()
+	} else {
+		p.mu.RLock()
+	}
+}
+
+func (p *partition) unlock(write bool) {
+	if write {
+		p.mu.Unlock()
+	} else {
+		p.mu.RUnlock()
+	}
+}
+
+// lookup 返回 key 所在的位置和链表中的前一个位置，调用方需持有锁
+func (p *partition) lookup(keyIndex uint64, key Partitionable) (index, prev int, ok bool) {
+	return p.lookupFunc(keyIndex, func(stored any) bool {
+		return keyEqual(key, stored)
+	})
+}
+
+// lookupFunc 与 lookup 相同，用 equal 比较 key，equal 不会逃逸
+func (p *partition) lookupFunc(keyIndex uint64, equal func(stored any) bool) (index, prev int, ok bool) {
+	index, ok = p.index.get(keyIndex)
+	prev = -1
+	for ok {
+		data := p.innerSlice[index]
+		if equal(data.key) {
+			return index, prev, true
+		}
+		prev, index = index, int(data.next)
+		ok = index >= 0
+	}
+	return -1, -1, false
+}
+
+// set 写入或覆盖 key，返回旧值，key 不存在时返回 nil，调用方需持有写锁
+func (p *partition) set(keyIndex uint64, key Partitionable, data *innerSlice) unsafe.Pointer {
+	if index, _, ok := p.lookup(keyIndex, key); ok {
+		old := p.innerSlice[index]
+		data.next = old.next
+		p.innerSlice[index] = data
+		return old.Value
+	}
+
+	p.insert(keyIndex, data)
+	return nil
+}
+
+// insert 把不存在的 key 放到链表头，调用方需持有写锁
+func (p *partition) insert(keyIndex uint64, data *innerSlice) {
+	data.next = -1
+	if head, ok := p.index.get(keyIndex); ok {
+		data.next = int32(head)
+	}
+	p.index.set(keyIndex, p.allocSlot(data))
+}
+
+// allocSlot 优先复用 free 中的位置，调用方需持有写锁
+func (p *partition) allocSlot(data *innerSlice) int {
+	if len(p.free) > 0 {
+		n := p.free[0]
+		p.free = p.free[1:]
+		p.innerSlice[n] = data
+		return n
+	}
+
+	p.innerSlice = append(p.innerSlice, data)
+	return len(p.innerSlice) - 1
+}
+
+// delete 删除 key 并返回被删除的值，调用方需持有写锁
+func (p *partition) delete(keyIndex uint64, key Partitionable) (*innerSlice, bool) {
+	index, prev, ok := p.lookup(keyIndex, key)
+	if !ok {
+		return nil, false
+	}
+	return p.unlink(keyIndex, index, prev), true
+}
+
+// unlink 从链表和 innerSlice 中移除 index 位置的值，prev 为链表中的前一个位置，调用方需持有写锁
+func (p *partition) unlink(keyIndex uint64, index, prev int) *innerSlice {
+	data := p.innerSlice[index]
+	switch {
+	case prev >= 0:
+		p.innerSlice[prev].next = data.next
+	case data.next >= 0:
+		p.index.set(keyIndex, int(data.next))
+	default:
+		p.index.delete(keyIndex)
+	}
+	p.free = append(p.free, index)
+	p.innerSlice[index] = nil
+	return data
+}
+
+// rangeData 遍历分区中的所有值，调用方需持有锁
+func (p *partition) rangeData(f func(data *innerSlice) bool) bool {
+	for _, data := range p.innerSlice {
+		if data != nil && !f(data) {
+			return false
+		}
+	}
+	return true
+}
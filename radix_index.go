@@ -0,0 +1,285 @@
+package HighPerformanceMap
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type radixNode struct {
+	prefix   string       // 父节点到当前节点的边
+	data     *innerSlice  // 非 nil 表示有 key 在此节点结束
+	children []*radixNode // 按 prefix[0] 排序
+}
+
+func (n *radixNode) child(c byte) (int, *radixNode) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].prefix[0] >= c
+	})
+	if i < len(n.children) && n.children[i].prefix[0] == c {
+		return i, n.children[i]
+	}
+	return i, nil
+}
+
+// collect 按字典序把以 n 为根的子树中大于 after 的值追加到 buf，直到 buf 中有 limit 个。
+// path 为 n 对应的 key，all 为 true 时不和 after 比较。返回 buf 是否已满
+func (n *radixNode) collect(path, after string, all bool, limit int, buf *[]*innerSlice) bool {
+	if n.data != nil && (all || path > after) {
+		if *buf = append(*buf, n.data); len(*buf) == limit {
+			return true
+		}
+	}
+	for _, child := range n.children {
+		childPath := path + child.prefix
+		switch {
+		case all || childPath > after:
+			// 子树中的 key 都以 childPath 开头，都大于 after
+			if child.collect(childPath, after, true, limit, buf) {
+				return true
+			}
+		case strings.HasPrefix(after, childPath):
+			if child.collect(childPath, after, false, limit, buf) {
+				return true
+			}
+		}
+		// 其余子树中的 key 都小于 after
+	}
+	return false
+}
+
+// radixTree 以 StrKey 的原始字符串建立的前缀索引
+type radixTree struct {
+	mu   sync.RWMutex // set/delete 自己加锁，collectPrefix 由调用方加锁
+	root radixNode
+	len  int
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (t *radixTree) set(data *innerSlice) {
+	key, ok := data.key.(string)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := &t.root
+	for {
+		if len(key) == 0 {
+			if n.data == nil {
+				t.len++
+			}
+			n.data = data
+			return
+		}
+
+		i, child := n.child(key[0])
+		if child == nil {
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = &radixNode{prefix: key, data: data}
+			t.len++
+			return
+		}
+
+		l := commonPrefixLen(key, child.prefix)
+		if l < len(child.prefix) {
+			split := &radixNode{prefix: child.prefix[:l], children: []*radixNode{child}}
+			child.prefix = child.prefix[l:]
+			n.children[i] = split
+			child = split
+		}
+		key = key[l:]
+		n = child
+	}
+}
+
+func (t *radixTree) delete(k any) {
+	key, ok := k.(string)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var parent *radixNode
+	var index int
+	n := &t.root
+	for len(key) > 0 {
+		i, child := n.child(key[0])
+		if child == nil || !strings.HasPrefix(key, child.prefix) {
+			return
+		}
+		parent, index, n = n, i, child
+		key = key[len(child.prefix):]
+	}
+	if n.data == nil {
+		return
+	}
+	n.data = nil
+	t.len--
+
+	if parent == nil {
+		return
+	}
+	switch len(n.children) {
+	case 0:
+		parent.children = append(parent.children[:index], parent.children[index+1:]...)
+		if parent != &t.root && parent.data == nil && len(parent.children) == 1 {
+			parent.merge()
+		}
+	case 1:
+		n.merge()
+	}
+}
+
+// merge 把唯一的子节点合并到当前节点
+func (n *radixNode) merge() {
+	child := n.children[0]
+	n.prefix += child.prefix
+	n.data = child.data
+	n.children = child.children
+}
+
+// collectPrefix 按字典序返回以 prefix 开头的 key 中大于 after 的最多 limit 个值，
+// all 为 true 时从第一个开始。调用方持有读锁
+func (t *radixTree) collectPrefix(prefix, after string, all bool, limit int) []*innerSlice {
+	n, path, rest := &t.root, "", prefix
+	for len(rest) > 0 {
+		_, child := n.child(rest[0])
+		if child == nil {
+			return nil
+		}
+		if strings.HasPrefix(child.prefix, rest) {
+			n, path = child, path+child.prefix
+			break
+		}
+		if !strings.HasPrefix(rest, child.prefix) {
+			return nil
+		}
+		n, path, rest = child, path+child.prefix, rest[len(child.prefix):]
+	}
+
+	var buf []*innerSlice
+	n.collect(path, after, all, limit, &buf)
+	return buf
+}
+
+// EnablePrefixIndex builds a radix index over the StrKey keys of the map so
+// that ScanPrefix and DeletePrefix do not need to visit every entry. Maps
+// without the index pay nothing on Set and Delete.
+func (m *concurrentMap) EnablePrefixIndex() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.prefixIndex != nil {
+		return
+	}
+	m.prefixIndex = &radixTree{}
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.rangeData(func(data *innerSlice) bool {
+				m.prefixIndex.set(data)
+				return true
+			})
+		}
+	}
+	if d := m.disk; d != nil {
+		d.mu.Lock()
+		for keyIndex, entries := range d.index {
+			for _, e := range entries {
+				m.prefixIndex.set(spilledStub(keyIndex, e.key))
+			}
+		}
+		d.mu.Unlock()
+	}
+	m.indexes = append(m.indexes, m.prefixIndex)
+}
+
+// ScanPrefix calls f in lexicographic order for every string key starting
+// with prefix. Without EnablePrefixIndex it falls back to a full scan in no
+// particular order. f runs without any lock held and may write to the map;
+// keys written during the scan may or may not be visited.
+func (m *concurrentMap) ScanPrefix(prefix string, f func(key string, value any) bool) {
+	m.walkPrefix(prefix, f)
+}
+
+// prefixScanBatch 遍历前缀索引时每次加锁复制的最大数量
+const prefixScanBatch = 64
+
+// walkPrefix 对 prefix 开头的未过期的 key 调用 f，调用 f 时不持有任何锁。前缀索引每次加读锁
+// 复制一批，数量从 1 开始加倍到 prefixScanBatch，下一批从上一批最后一个 key 之后开始
+func (m *concurrentMap) walkPrefix(prefix string, f func(key string, value any) bool) {
+	m.mu.RLock()
+	t := m.prefixIndex
+	m.mu.RUnlock()
+
+	if t != nil {
+		after, all, size := "", true, 0
+		for {
+			if size = size*2 + 1; size > prefixScanBatch {
+				size = prefixScanBatch
+			}
+			t.mu.RLock()
+			buf := t.collectPrefix(prefix, after, all, size)
+			t.mu.RUnlock()
+
+			for _, data := range buf {
+				if v, ok := m.indexedValue(data); ok && !f(data.key.(string), v) {
+					return
+				}
+			}
+			if len(buf) < size {
+				return
+			}
+			after, all = buf[len(buf)-1].key.(string), false
+		}
+	}
+
+	// 没有前缀索引时先复制内存中全部匹配的值，遍历结束后再调用 f，然后遍历磁盘
+	var matched []*innerSlice
+	m.rangeData(func(data *innerSlice) bool {
+		if key, ok := data.key.(string); ok && strings.HasPrefix(key, prefix) {
+			matched = append(matched, data)
+		}
+		return true
+	})
+	for _, data := range matched {
+		if !m.expired(data) && !f(data.key.(string), m.getValue(data.Value)) {
+			return
+		}
+	}
+	if m.disk != nil {
+		m.disk.rangeEntries(m.now(), func(key, value any) bool {
+			s, ok := key.(string)
+			return !ok || !strings.HasPrefix(s, prefix) || f(s, value)
+		})
+	}
+}
+
+// DeletePrefix deletes every string key starting with prefix and returns the
+// number of deleted keys. Keys are deleted one by one, so keys set while it
+// runs may survive.
+func (m *concurrentMap) DeletePrefix(prefix string) int {
+	var keys []string
+	m.walkPrefix(prefix, func(key string, value any) bool {
+		keys = append(keys, key)
+		return true
+	})
+
+	n := 0
+	for _, key := range keys {
+		if m.delete(StrKey(key)) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,159 @@
+package HighPerformanceMap
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func setTenants(mapData *concurrentMap) {
+	for tenant := 40; tenant < 45; tenant++ {
+		for user := 0; user < 20; user++ {
+			key := "tenant:" + strconv.Itoa(tenant) + ":user:" + strconv.Itoa(user)
+			mapData.Set(StrKey(key), user)
+		}
+	}
+	mapData.Set(StrKey("tenant:4"), -1)
+	mapData.Set(I64Key(42), -1)
+}
+
+func TestScanPrefix(t *testing.T) {
+	indexData := CreateConcurrentSliceMap(99)
+	indexData.EnablePrefixIndex()
+	setTenants(indexData)
+	scanData := CreateConcurrentSliceMap(99)
+	setTenants(scanData)
+
+	for _, mapData := range []*concurrentMap{indexData, scanData} {
+		n := 0
+		mapData.ScanPrefix("tenant:42:", func(key string, value any) bool {
+			n++
+			return true
+		})
+		if n != 20 {
+			t.Errorf("scan prefix --> %v", n)
+		}
+
+		n = 0
+		mapData.ScanPrefix("tenant:4", func(key string, value any) bool {
+			n++
+			return true
+		})
+		if n != 101 {
+			t.Errorf("scan prefix --> %v", n)
+		}
+	}
+
+	prev := ""
+	indexData.ScanPrefix("", func(key string, value any) bool {
+		if key <= prev {
+			t.Fatalf("%q after %q", key, prev)
+		}
+		prev = key
+		return true
+	})
+}
+
+func TestDeletePrefix(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	setTenants(mapData)
+	mapData.EnablePrefixIndex()
+	ch, cancel := mapData.WatchPrefix("tenant:42:", WithBufferSize(100))
+	defer cancel()
+
+	if n := mapData.DeletePrefix("tenant:42:"); n != 20 {
+		t.Errorf("delete prefix --> %v", n)
+	}
+	if len(ch) != 20 {
+		t.Errorf("delete events --> %v", len(ch))
+	}
+	if mapData.Len() != 82 || mapData.prefixIndex.len != 81 {
+		t.Errorf("len --> %v, index len --> %v", mapData.Len(), mapData.prefixIndex.len)
+	}
+
+	mapData.Delete(StrKey("tenant:4"))
+	mapData.Set(StrKey("tenant:42:user:1"), 1)
+	n := 0
+	mapData.ScanPrefix("tenant:4", func(key string, value any) bool {
+		n++
+		return true
+	})
+	if n != 81 {
+		t.Errorf("scan prefix --> %v", n)
+	}
+
+	if n = mapData.DeletePrefix(""); n != 81 || mapData.Len() != 1 {
+		t.Errorf("delete all --> %v, len --> %v", n, mapData.Len())
+	}
+}
+
+func TestRadixTreeRandom(t *testing.T) {
+	tree := &radixTree{}
+	want := make(map[string]bool)
+	words := []string{"a", "ab", "abc", "abd", "b", "ba", "bab", "abcd", "ac", ""}
+
+	for i := 0; i < 10000; i++ {
+		key := words[rand.Intn(len(words))] + words[rand.Intn(len(words))]
+		if rand.Intn(3) == 0 {
+			tree.delete(key)
+			delete(want, key)
+		} else {
+			tree.set(&innerSlice{key: key})
+			want[key] = true
+		}
+	}
+
+	// 每批 3 个，下一批从上一批最后一个 key 之后开始
+	got := 0
+	after, all := "", true
+	for {
+		buf := tree.collectPrefix("", after, all, 3)
+		for _, data := range buf {
+			key := data.key.(string)
+			if !want[key] || got > 0 && key <= after {
+				t.Errorf("unexpected key %q after %q", key, after)
+			}
+			after = key
+			got++
+		}
+		all = false
+		if len(buf) < 3 {
+			break
+		}
+	}
+	if got != len(want) || tree.len != len(want) {
+		t.Errorf("got %v keys, want %v", got, len(want))
+	}
+}
+
+func TestScanPrefixWriteInCallback(t *testing.T) {
+	for _, index := range []bool{true, false} {
+		mapData := CreateConcurrentSliceMap(7)
+		if index {
+			mapData.EnablePrefixIndex()
+		}
+		for i := 0; i < 200; i++ {
+			mapData.Set(StrKey("t:"+strconv.Itoa(i)), i)
+		}
+
+		// 回调中写入和删除 key 不会死锁
+		n := 0
+		mapData.ScanPrefix("t:", func(key string, value any) bool {
+			mapData.Set(StrKey("u:"+key), value)
+			mapData.Delete(StrKey(key))
+			n++
+			return true
+		})
+		if n != 200 || mapData.Len() != 200 {
+			t.Errorf("scan --> %v, len --> %v", n, mapData.Len())
+		}
+		n = 0
+		mapData.ScanPrefix("t:", func(key string, value any) bool {
+			n++
+			return true
+		})
+		if n != 0 {
+			t.Errorf("scan after delete --> %v", n)
+		}
+	}
+}
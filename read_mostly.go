@@ -0,0 +1,270 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// readMostlyMap 参照 sync.Map 的 read / dirty 设计：读取不可变的 read 分区时不加锁，
+// 新 key 写入加锁的 dirty 分区，miss 次数达到 dirty 的大小后把 dirty 提升为 read。
+// 两个分区各自有 innerSlice，innerSlice.Value 指向共享的 readMostlyEntry。
+type readMostlyMap struct {
+	mu     sync.Mutex
+	read   atomic.Value // *readOnlyPartition
+	dirty  *partition   // 包含 read 中未删除的 key 和新 key，nil 表示与 read 相同
+	misses int          // read 中找不到、需要加锁读取 dirty 的次数
+	count  int64        // key 的数量，原子操作
+}
+
+type readOnlyPartition struct {
+	p       *partition // 发布之后不再修改
+	amended bool       // dirty 中有 read 没有的 key
+}
+
+// readMostlyEntry 的 p 是 *any；nil 表示已删除；expunged 表示已删除且不在 dirty 中
+type readMostlyEntry struct {
+	p unsafe.Pointer
+}
+
+var expunged = unsafe.Pointer(new(any))
+
+// CreateReadMostlyMap creates a map for data that is almost never written,
+// such as configuration. Reads of keys that existed at the last promotion
+// take no lock; writes of new keys go to a locked dirty overlay, which is
+// promoted to the read-only index once enough reads have missed it.
+func CreateReadMostlyMap() *readMostlyMap {
+	m := &readMostlyMap{}
+	m.read.Store(&readOnlyPartition{p: newPartitions(1, BuiltinMapBackend)[0]})
+	return m
+}
+
+func (m *readMostlyMap) loadRead() *readOnlyPartition {
+	return m.read.Load().(*readOnlyPartition)
+}
+
+func (p *partition) entry(keyIndex uint64, equal func(stored any) bool) (*readMostlyEntry, bool) {
+	if index, _, ok := p.lookupFunc(keyIndex, equal); ok {
+		return (*readMostlyEntry)(p.innerSlice[index].Value), true
+	}
+	return nil, false
+}
+
+func (m *readMostlyMap) Get(key Partitionable) (any, bool) {
+	return m.getFunc(key.PartitionKey(), func(stored any) bool {
+		return keyEqual(key, stored)
+	})
+}
+
+// GetString is Get(StrKey(key)) without allocating a key.
+func (m *readMostlyMap) GetString(key string) (any, bool) {
+	return m.getFunc(hash(key), func(stored any) bool {
+		s, ok := stored.(string)
+		return ok && s == key
+	})
+}
+
+// GetInt64 is Get(I64Key(key)) without allocating a key.
+func (m *readMostlyMap) GetInt64(key int64) (any, bool) {
+	keyIndex := uint64(key)
+	return m.getFunc(keyIndex, func(stored any) bool {
+		u, ok := stored.(uint64)
+		return ok && u == keyIndex
+	})
+}
+
+func (m *readMostlyMap) getFunc(keyIndex uint64, equal func(stored any) bool) (any, bool) {
+	read := m.loadRead()
+	e, ok := read.p.entry(keyIndex, equal)
+	if !ok && read.amended {
+		m.mu.Lock()
+		// 加锁期间 dirty 可能已经被提升
+		read = m.loadRead()
+		e, ok = read.p.entry(keyIndex, equal)
+		if !ok && read.amended {
+			e, ok = m.dirty.entry(keyIndex, equal)
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	if !ok {
+		return nil, false
+	}
+	return e.load()
+}
+
+func (m *readMostlyMap) Set(key Partitionable, v any) {
+	keyIndex := key.PartitionKey()
+	equal := func(stored any) bool {
+		return keyEqual(key, stored)
+	}
+
+	read := m.loadRead()
+	if e, ok := read.p.entry(keyIndex, equal); ok && e.tryStore(m, &v) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	read = m.loadRead()
+	if e, ok := read.p.entry(keyIndex, equal); ok {
+		if e.unexpungeLocked() {
+			// 已从 dirty 中清除，需要重新加入
+			m.dirty.insert(keyIndex, &innerSlice{key: key.Value(), Value: unsafe.Pointer(e)})
+		}
+		e.storeLocked(m, &v)
+		return
+	}
+	if read.amended {
+		if e, ok := m.dirty.entry(keyIndex, equal); ok {
+			e.storeLocked(m, &v)
+			return
+		}
+	} else {
+		m.dirtyLocked()
+		m.read.Store(&readOnlyPartition{p: read.p, amended: true})
+	}
+	e := &readMostlyEntry{p: unsafe.Pointer(&v)}
+	m.dirty.insert(keyIndex, &innerSlice{key: key.Value(), Value: unsafe.Pointer(e)})
+	atomic.AddInt64(&m.count, 1)
+}
+
+func (m *readMostlyMap) Delete(key Partitionable) {
+	keyIndex := key.PartitionKey()
+	equal := func(stored any) bool {
+		return keyEqual(key, stored)
+	}
+
+	read := m.loadRead()
+	e, ok := read.p.entry(keyIndex, equal)
+	if !ok && read.amended {
+		m.mu.Lock()
+		read = m.loadRead()
+		e, ok = read.p.entry(keyIndex, equal)
+		if !ok && read.amended {
+			var data *innerSlice
+			if data, ok = m.dirty.delete(keyIndex, key); ok {
+				e = (*readMostlyEntry)(data.Value)
+			}
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	if ok && e.delete() {
+		atomic.AddInt64(&m.count, -1)
+	}
+}
+
+func (m *readMostlyMap) Len() int {
+	return int(atomic.LoadInt64(&m.count))
+}
+
+// Range 先把 dirty 提升为 read，再不加锁地遍历 read
+func (m *readMostlyMap) Range(f func(key, value any) bool) {
+	read := m.loadRead()
+	if read.amended {
+		m.mu.Lock()
+		read = m.loadRead()
+		if read.amended {
+			read = &readOnlyPartition{p: m.dirty}
+			m.read.Store(read)
+			m.dirty = nil
+			m.misses = 0
+		}
+		m.mu.Unlock()
+	}
+
+	read.p.rangeData(func(data *innerSlice) bool {
+		v, ok := (*readMostlyEntry)(data.Value).load()
+		return !ok || f(data.key, v)
+	})
+}
+
+// missLocked 记录一次 miss，miss 次数不少于 dirty 中的 key 数时提升 dirty
+func (m *readMostlyMap) missLocked() {
+	m.misses++
+	if m.misses < len(m.dirty.innerSlice)-len(m.dirty.free) {
+		return
+	}
+	m.read.Store(&readOnlyPartition{p: m.dirty})
+	m.dirty = nil
+	m.misses = 0
+}
+
+// dirtyLocked 用 read 中未删除的 key 创建 dirty，已删除的 key 标记为 expunged
+func (m *readMostlyMap) dirtyLocked() {
+	if m.dirty != nil {
+		return
+	}
+
+	read := m.loadRead()
+	m.dirty = newPartitions(1, BuiltinMapBackend)[0]
+	read.p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+		for index >= 0 {
+			data := read.p.innerSlice[index]
+			index = int(data.next)
+			if !(*readMostlyEntry)(data.Value).tryExpungeLocked() {
+				m.dirty.insert(keyIndex, &innerSlice{key: data.key, Value: data.Value})
+			}
+		}
+		return true
+	})
+}
+
+func (e *readMostlyEntry) load() (any, bool) {
+	p := atomic.LoadPointer(&e.p)
+	if p == nil || p == expunged {
+		return nil, false
+	}
+	return *(*any)(p), true
+}
+
+// tryStore 在 entry 没有被 expunged 时写入
+func (e *readMostlyEntry) tryStore(m *readMostlyMap, v *any) bool {
+	for {
+		p := atomic.LoadPointer(&e.p)
+		if p == expunged {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(v)) {
+			if p == nil {
+				atomic.AddInt64(&m.count, 1)
+			}
+			return true
+		}
+	}
+}
+
+func (e *readMostlyEntry) unexpungeLocked() bool {
+	return atomic.CompareAndSwapPointer(&e.p, expunged, nil)
+}
+
+func (e *readMostlyEntry) storeLocked(m *readMostlyMap, v *any) {
+	if atomic.SwapPointer(&e.p, unsafe.Pointer(v)) == nil {
+		atomic.AddInt64(&m.count, 1)
+	}
+}
+
+func (e *readMostlyEntry) delete() bool {
+	for {
+		p := atomic.LoadPointer(&e.p)
+		if p == nil || p == expunged {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
+			return true
+		}
+	}
+}
+
+func (e *readMostlyEntry) tryExpungeLocked() bool {
+	p := atomic.LoadPointer(&e.p)
+	for p == nil {
+		if atomic.CompareAndSwapPointer(&e.p, nil, expunged) {
+			return true
+		}
+		p = atomic.LoadPointer(&e.p)
+	}
+	return p == expunged
+}
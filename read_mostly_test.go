@@ -0,0 +1,82 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestReadMostlyMap(t *testing.T) {
+	mapData := CreateReadMostlyMap()
+	for i := 0; i < 100; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	if mapData.Len() != 100 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+
+	// miss 次数达到 dirty 的大小后提升为 read
+	for i := 0; i < 100; i++ {
+		if v, ok := mapData.GetString(strconv.Itoa(i)); !ok || v.(int) != i {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if read := mapData.loadRead(); read.amended || mapData.dirty != nil {
+		t.Error("dirty not promoted")
+	}
+
+	// 已有的 key 不加锁更新
+	mapData.Set(StrKey("1"), 101)
+	if v, _ := mapData.Get(StrKey("1")); v.(int) != 101 || mapData.dirty != nil {
+		t.Errorf("update --> %v", v)
+	}
+
+	mapData.Delete(StrKey("2"))
+	mapData.Set(StrKey("new"), -1)
+	if _, ok := mapData.Get(StrKey("2")); ok {
+		t.Error("deleted key found")
+	}
+	mapData.Set(StrKey("2"), 2)
+	mapData.Delete(StrKey("new"))
+	if v, ok := mapData.Get(StrKey("2")); !ok || v.(int) != 2 {
+		t.Errorf("set after expunge --> %v, %v", v, ok)
+	}
+
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if n != 100 || mapData.Len() != 100 {
+		t.Errorf("range --> %v, len --> %v", n, mapData.Len())
+	}
+}
+
+func TestReadMostlyMapGoroutine(t *testing.T) {
+	mapData := CreateReadMostlyMap()
+	num := 2000
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < num; i++ {
+				key := int64(g*num + i)
+				mapData.Set(I64Key(key), i)
+				if v, ok := mapData.GetInt64(key); !ok || v.(int) != i {
+					t.Errorf("get %v --> %v, %v", key, v, ok)
+					return
+				}
+				if i%2 == 0 {
+					mapData.Delete(I64Key(key))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if mapData.Len() != 4*num/2 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
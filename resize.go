@@ -0,0 +1,139 @@
+package HighPerformanceMap
+
+import (
+	"sync/atomic"
+)
+
+// Partitions returns the current number of partitions.
+func (m *concurrentMap) Partitions() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lenOfBucket
+}
+
+// Resize changes the number of partitions to n. Keys are moved to the new
+// partitions progressively: every Set or Delete migrates one old partition,
+// so no single call pays for rehashing the whole map.
+func (m *concurrentMap) Resize(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resizeLocked(n)
+}
+
+// SetResizePolicy enables automatic resizing: the partition count doubles when
+// the average number of keys per partition exceeds maxLoad and halves, but not
+// below the initial lenOfBucket, when it drops under minLoad. minLoad should
+// be well below maxLoad/2 to avoid resizing back and forth. maxLoad 0 turns
+// the policy off.
+func (m *concurrentMap) SetResizePolicy(minLoad, maxLoad int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.minLoad, m.maxLoad = minLoad, maxLoad
+}
+
+// resizeLocked 切换到 n 个分区的新表，调用方需持有 m.mu 的写锁
+func (m *concurrentMap) resizeLocked(n int) {
+	if n < 1 || n == m.lenOfBucket {
+		return
+	}
+
+	// 上一次迁移还没结束，此时没有其它读写，直接迁移完
+	for m.oldPartitions != nil && !m.migrateLocked() {
+	}
+
+	partitions := newPartitions(n, m.backend)
+	if m.lockFree() {
+		for _, p := range partitions {
+			p.publish()
+		}
+	}
+	m.storeTable(partitions, m.partitions)
+	m.rehashIdx = 0
+}
+
+// migrateLocked 把下一个旧分区的所有 key 移到新分区表，返回是否全部迁移完，
+// 调用方需持有 m.mu 的写锁，或者 m.mu 的读锁加 rehashMu 的写锁
+func (m *concurrentMap) migrateLocked() bool {
+	if m.rehashIdx >= len(m.oldPartitions) {
+		return true
+	}
+
+	p := m.oldPartitions[m.rehashIdx]
+	p.mu.Lock()
+	// 按目标分区分组，每个目标分区只加一次锁
+	type entry struct {
+		keyIndex uint64
+		data     *innerSlice
+	}
+	moved := make(map[*partition][]entry)
+	p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+		target := m.partitions[keyIndex%uint64(m.lenOfBucket)]
+		for index >= 0 {
+			data := p.innerSlice[index]
+			moved[target] = append(moved[target], entry{keyIndex, data})
+			index = int(data.next)
+		}
+		return true
+	})
+	for target, entries := range moved {
+		target.mu.Lock()
+		for _, e := range entries {
+			target.insert(e.keyIndex, e.data)
+			if m.weigher != nil {
+				atomic.AddInt64(&target.weight, m.weigher(e.data.key, m.getValue(e.data.Value)))
+			}
+			if m.lockFree() {
+				target.updateView(e.keyIndex, e.data.key, e.data)
+			}
+		}
+		target.mu.Unlock()
+	}
+	p.migrated = true
+	atomic.StoreInt64(&p.weight, 0)
+	p.index, p.innerSlice, p.free = nil, nil, nil
+	if m.lockFree() {
+		p.publish()
+	}
+	p.mu.Unlock()
+
+	m.rehashIdx++
+	return m.rehashIdx >= len(m.oldPartitions)
+}
+
+// rehash 在每次写操作之后调用，推进迁移或按负载自动扩缩容
+func (m *concurrentMap) rehash() {
+	m.mu.RLock()
+	rehashing := m.oldPartitions != nil
+	done := false
+	// 有遍历或事务提交持有读锁时跳过，由之后的写操作继续迁移
+	if rehashing && m.rehashMu.TryLock() {
+		done = m.migrateLocked()
+		m.rehashMu.Unlock()
+	}
+	grow, shrink := false, false
+	if !rehashing && m.maxLoad > 0 {
+		load := int(atomic.LoadInt64(&m.count)) / m.lenOfBucket
+		grow = load > m.maxLoad
+		shrink = load < m.minLoad && m.lenOfBucket/2 >= m.minBucket
+	}
+	m.mu.RUnlock()
+
+	if !done && !grow && !shrink {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case done:
+		if m.oldPartitions != nil && m.rehashIdx >= len(m.oldPartitions) {
+			m.storeTable(m.partitions, nil)
+		}
+	case m.oldPartitions != nil:
+		// 其它写操作已经开始了新的扩缩容
+	case grow && int(atomic.LoadInt64(&m.count))/m.lenOfBucket > m.maxLoad:
+		m.resizeLocked(m.lenOfBucket * 2)
+	case shrink && int(atomic.LoadInt64(&m.count))/m.lenOfBucket < m.minLoad && m.lenOfBucket/2 >= m.minBucket:
+		m.resizeLocked(m.lenOfBucket / 2)
+	}
+}
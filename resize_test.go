@@ -0,0 +1,151 @@
+package HighPerformanceMap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	num := 10000
+	mapData := CreateConcurrentSliceMap(4)
+	for i := 0; i < num; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+
+	mapData.Resize(64)
+	if mapData.Partitions() != 64 || mapData.oldPartitions == nil {
+		t.Fatal("resize not started")
+	}
+
+	// 迁移期间每个 key 只出现一次
+	seen := make(map[string]bool)
+	mapData.Range(func(key, value any) bool {
+		if seen[key.(string)] {
+			t.Fatalf("key %v seen twice", key)
+		}
+		seen[key.(string)] = true
+		return true
+	})
+	if len(seen) != num {
+		t.Errorf("range --> %v", len(seen))
+	}
+
+	for i := 0; i < 2; i++ {
+		mapData.Delete(StrKey(strconv.Itoa(i)))
+	}
+	if mapData.rehashIdx != 2 {
+		t.Errorf("rehash index --> %v", mapData.rehashIdx)
+	}
+	for i := 2; i < num; i++ {
+		if v, ok := mapData.Get(StrKey(strconv.Itoa(i))); !ok || v.(int) != i {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+
+	mapData.Resize(8)
+	if mapData.Partitions() != 8 || len(mapData.oldPartitions) != 64 {
+		t.Fatal("unfinished resize not completed")
+	}
+	for i := 0; i < 100; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	if mapData.oldPartitions != nil {
+		t.Error("resize not finished")
+	}
+	if mapData.Len() != num {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+	for i := 0; i < num; i++ {
+		if v, ok := mapData.GetString(strconv.Itoa(i)); !ok || v.(int) != i {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestResizePolicy(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(2)
+	mapData.SetResizePolicy(2, 16)
+
+	for i := 0; i < 10000; i++ {
+		mapData.Set(I64Key(int64(i)), i)
+	}
+	if n := mapData.Partitions(); n < 10000/16/2 {
+		t.Errorf("partitions after grow --> %v", n)
+	}
+
+	for i := 0; i < 10000; i++ {
+		mapData.Delete(I64Key(int64(i)))
+	}
+	// 迁移由写操作推动
+	for i := 0; i < 100; i++ {
+		mapData.Delete(I64Key(-1))
+	}
+	if n := mapData.Partitions(); n != 2 {
+		t.Errorf("partitions after shrink --> %v", n)
+	}
+}
+
+func TestResizeGoroutine(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(3)
+	mapData.EnablePrefixIndex()
+	goroutineNum := 8
+	num := 2000
+	wg := sync.WaitGroup{}
+	stop := make(chan struct{})
+
+	go func() {
+		sizes := []int{7, 64, 5, 128, 1, 33}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				mapData.Resize(sizes[i%len(sizes)])
+			}
+		}
+	}()
+
+	for g := 0; g < goroutineNum; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			prefix := strconv.Itoa(g) + ":"
+			for i := 0; i < num; i++ {
+				key := prefix + strconv.Itoa(i)
+				mapData.Set(StrKey(key), i)
+				if v, ok := mapData.Get(StrKey(key)); !ok || v.(int) != i {
+					t.Errorf("get %v --> %v, %v", key, v, ok)
+					return
+				}
+				if i%3 == 0 {
+					mapData.Delete(StrKey(key))
+					if _, ok := mapData.Get(StrKey(key)); ok {
+						t.Errorf("deleted key %v found", key)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(stop)
+
+	want := goroutineNum * (num - (num+2)/3)
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	if mapData.Len() != want || n != want {
+		t.Errorf("len --> %v, range --> %v, want %v", mapData.Len(), n, want)
+	}
+	n = 0
+	mapData.ScanPrefix("3:", func(key string, value any) bool {
+		n++
+		return true
+	})
+	if n != want/goroutineNum {
+		t.Errorf("scan prefix --> %v", n)
+	}
+}
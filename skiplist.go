@@ -0,0 +1,117 @@
+package HighPerformanceMap
+
+import (
+	"math/rand"
+)
+
+const (
+	skipListMaxLevel = 32
+	skipListP        = 4 // 每升一层的概率为 1/skipListP
+)
+
+type skipListNode struct {
+	key  int64
+	data *innerSlice   // 指向 map 中保存值的位置
+	prev *skipListNode // 倒序遍历使用
+	next []*skipListNode
+}
+
+// skipList 按 int64 key 排序，由 int64IndexShard 的锁保护
+type skipList struct {
+	head  *skipListNode
+	level int
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipListNode{next: make([]*skipListNode, skipListMaxLevel)},
+		level: 1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Intn(skipListP) == 0 {
+		level++
+	}
+	return level
+}
+
+// findLess 找到每一层最后一个 key 小于 key 的节点
+func (l *skipList) findLess(key int64, update []*skipListNode) *skipListNode {
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x
+}
+
+func (l *skipList) set(key int64, data *innerSlice) {
+	var update [skipListMaxLevel]*skipListNode
+	x := l.findLess(key, update[:])
+	if next := x.next[0]; next != nil && next.key == key {
+		next.data = data
+		return
+	}
+
+	level := randomLevel()
+	for i := l.level; i < level; i++ {
+		update[i] = l.head
+	}
+	if level > l.level {
+		l.level = level
+	}
+
+	node := &skipListNode{key: key, data: data, next: make([]*skipListNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+
+	if x != l.head {
+		node.prev = x
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	}
+}
+
+func (l *skipList) delete(key int64) {
+	var update [skipListMaxLevel]*skipListNode
+	x := l.findLess(key, update[:]).next[0]
+	if x == nil || x.key != key {
+		return
+	}
+
+	for i := 0; i < len(x.next); i++ {
+		update[i].next[i] = x.next[i]
+	}
+	if x.next[0] != nil {
+		x.next[0].prev = x.prev
+	}
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+}
+
+// ceiling 返回第一个 key 大于等于 key 的节点
+func (l *skipList) ceiling(key int64) *skipListNode {
+	return l.findLess(key, nil).next[0]
+}
+
+// floor 返回最后一个 key 小于等于 key 的节点
+func (l *skipList) floor(key int64) *skipListNode {
+	x := l.findLess(key, nil)
+	if next := x.next[0]; next != nil && next.key == key {
+		return next
+	}
+	if x == l.head {
+		return nil
+	}
+	return x
+}
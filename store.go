@@ -0,0 +1,282 @@
+package HighPerformanceMap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrStoreClosed is returned by a store-bound map after Close.
+var ErrStoreClosed = errors.New("HighPerformanceMap: store is closed")
+
+// Store is a backing store a map is bound to with BindStore.
+type Store interface {
+	// Load returns the stored value of key and whether it exists.
+	Load(key Partitionable) (any, bool, error)
+	Save(key Partitionable, value any) error
+	Delete(key Partitionable) error
+	// SaveBatch saves and deletes several keys at once.
+	SaveBatch(entries []StoreEntry) error
+}
+
+// StoreEntry is one write passed to Store.SaveBatch.
+type StoreEntry struct {
+	Key     Partitionable
+	Value   any
+	Deleted bool // 为 true 时删除 Key，忽略 Value
+}
+
+// StoreMode 写入 map 时何时写入 Store
+type StoreMode int
+
+const (
+	WriteThrough StoreMode = iota // Set、Delete 返回前写入 Store，失败时不修改 map
+	WriteBehind                   // 合并后按周期批量写入 Store
+)
+
+// StoreConfig 绑定 Store 的参数，零值使用默认值
+type StoreConfig struct {
+	Mode          StoreMode
+	FlushInterval time.Duration   // WriteBehind 的写入周期，默认 1s
+	MaxBatch      int             // 每次 SaveBatch 的最大数量，默认 256
+	MaxRetries    int             // SaveBatch 失败后的重试次数，默认 3
+	RetryBackoff  time.Duration   // 第一次重试前的等待时间，之后每次加倍，默认 100ms
+	OnError       func(err error) // WriteBehind 重试后仍然失败时调用，写入会留到下一个周期
+}
+
+// storeMap 绑定了 Store 的 map，同一个 key 的写入和从 Store 加载由 stripes 串行化，
+// 保证 map 和 Store 中的值一致
+type storeMap struct {
+	m       *concurrentMap
+	store   Store
+	cfg     StoreConfig
+	stripes [storeStripes]sync.Mutex
+
+	mu      sync.Mutex
+	pending map[any]pendingWrite // key.Value() → 未写入的修改，WriteBehind 时使用
+	seq     uint64               // 最后一次修改的序号
+	closed  bool
+
+	flushMu sync.Mutex // 同一时间只有一次 flush
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+const storeStripes = 64
+
+// pendingWrite 写入 Store 之前一直保留在 pending 中，Get 不会从 Store 读到旧值
+type pendingWrite struct {
+	StoreEntry
+	seq uint64
+}
+
+// BindStore binds the map to store and returns the map to write through.
+// Writes made directly on m are not persisted. Close must be called to stop
+// the write-behind flusher and flush the pending writes.
+func (m *concurrentMap) BindStore(store Store, cfg StoreConfig) *storeMap {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 256
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 100 * time.Millisecond
+	}
+
+	s := &storeMap{
+		m:       m,
+		store:   store,
+		cfg:     cfg,
+		pending: make(map[any]pendingWrite),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if cfg.Mode != WriteBehind {
+		close(s.done)
+		return s
+	}
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(cfg.FlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil && cfg.OnError != nil {
+					cfg.OnError(err)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+func (s *storeMap) stripe(key Partitionable) *sync.Mutex {
+	return &s.stripes[key.PartitionKey()%storeStripes]
+}
+
+// write 在 key 的 stripe 锁下写入 Store 或加入 pending，再修改 map
+func (s *storeMap) write(e StoreEntry, apply func()) error {
+	mu := s.stripe(e.Key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.mu.Lock()
+	closed := s.closed
+	if !closed && s.cfg.Mode == WriteBehind {
+		s.seq++
+		s.pending[e.Key.Value()] = pendingWrite{e, s.seq}
+	}
+	s.mu.Unlock()
+	if closed {
+		return ErrStoreClosed
+	}
+
+	if s.cfg.Mode != WriteBehind {
+		var err error
+		if e.Deleted {
+			err = s.store.Delete(e.Key)
+		} else {
+			err = s.store.Save(e.Key, e.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	apply()
+	return nil
+}
+
+// Set stores v in the map and, depending on the mode, in the store before
+// returning or at the next flush. In write-through mode the map is left
+// unchanged when the store fails.
+func (s *storeMap) Set(key Partitionable, v any) error {
+	return s.write(StoreEntry{Key: key, Value: v}, func() {
+		s.m.Set(key, v)
+	})
+}
+
+// Delete removes key from the map and the store.
+func (s *storeMap) Delete(key Partitionable) error {
+	return s.write(StoreEntry{Key: key, Deleted: true}, func() {
+		s.m.Delete(key)
+	})
+}
+
+// Get returns the value of key from the map, loading it from the store and
+// caching it when the map does not have it.
+func (s *storeMap) Get(key Partitionable) (any, bool, error) {
+	if v, ok := s.m.Get(key); ok {
+		return v, true, nil
+	}
+
+	mu := s.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+	if v, ok := s.m.Get(key); ok {
+		return v, true, nil
+	}
+	// 还未写入 Store 的修改比 Store 中的值新
+	s.mu.Lock()
+	e, ok := s.pending[key.Value()]
+	s.mu.Unlock()
+	if ok {
+		return e.Value, !e.Deleted, nil
+	}
+
+	v, ok, err := s.store.Load(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	s.m.Set(key, v)
+	return v, true, nil
+}
+
+// Pending returns the number of writes waiting for the next flush.
+func (s *storeMap) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Flush writes the pending writes to the store in batches of MaxBatch,
+// retrying each batch with exponential backoff. Writes that still fail are
+// kept for the next flush.
+func (s *storeMap) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	writes := make([]pendingWrite, 0, len(s.pending))
+	for _, w := range s.pending {
+		writes = append(writes, w)
+	}
+	s.mu.Unlock()
+
+	batch := make([]StoreEntry, 0, s.cfg.MaxBatch)
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > s.cfg.MaxBatch {
+			n = s.cfg.MaxBatch
+		}
+		batch = batch[:0]
+		for _, w := range writes[:n] {
+			batch = append(batch, w.StoreEntry)
+		}
+		if err := s.saveBatch(batch); err != nil {
+			return err
+		}
+		s.saved(writes[:n])
+		writes = writes[n:]
+	}
+	return nil
+}
+
+// saved 从 pending 中删除已写入的修改，flush 期间又被写入的 key 保留新的修改
+func (s *storeMap) saved(writes []pendingWrite) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range writes {
+		k := w.Key.Value()
+		if s.pending[k].seq == w.seq {
+			delete(s.pending, k)
+		}
+	}
+}
+
+func (s *storeMap) saveBatch(batch []StoreEntry) error {
+	backoff := s.cfg.RetryBackoff
+	err := s.store.SaveBatch(batch)
+	for i := 0; err != nil && i < s.cfg.MaxRetries; i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = s.store.SaveBatch(batch)
+	}
+	return err
+}
+
+// Close stops the write-behind flusher and flushes the pending writes.
+// Later writes return ErrStoreClosed.
+func (s *storeMap) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.cfg.Mode != WriteBehind {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	return s.Flush()
+}
@@ -0,0 +1,199 @@
+package HighPerformanceMap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore 测试用的 Store，记录每次调用，fail 大于 0 时接下来的 fail 次写入失败
+type memStore struct {
+	mu      sync.Mutex
+	data    map[any]any
+	batches [][]StoreEntry
+	writes  int
+	fail    int
+}
+
+var errStore = errors.New("store unavailable")
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[any]any)}
+}
+
+func (s *memStore) failing() bool {
+	if s.fail > 0 {
+		s.fail--
+		return true
+	}
+	return false
+}
+
+func (s *memStore) Load(key Partitionable) (any, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key.Value()]
+	return v, ok, nil
+}
+
+func (s *memStore) Save(key Partitionable, value any) error {
+	return s.SaveBatch([]StoreEntry{{Key: key, Value: value}})
+}
+
+func (s *memStore) Delete(key Partitionable) error {
+	return s.SaveBatch([]StoreEntry{{Key: key, Deleted: true}})
+}
+
+func (s *memStore) SaveBatch(entries []StoreEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing() {
+		return errStore
+	}
+	s.batches = append(s.batches, append([]StoreEntry(nil), entries...))
+	for _, e := range entries {
+		s.writes++
+		if e.Deleted {
+			delete(s.data, e.Key.Value())
+		} else {
+			s.data[e.Key.Value()] = e.Value
+		}
+	}
+	return nil
+}
+
+func (s *memStore) get(key any) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := newMemStore()
+	mapData := CreateConcurrentSliceMap(7)
+	s := mapData.BindStore(store, StoreConfig{Mode: WriteThrough})
+	defer s.Close()
+
+	if err := s.Set(StrKey("Hello"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.get("Hello"); !ok || v != 1 {
+		t.Errorf("store --> %v, %v", v, ok)
+	}
+
+	store.fail = 1
+	if err := s.Set(StrKey("Hello"), 2); err != errStore {
+		t.Errorf("set err --> %v, want %v", err, errStore)
+	}
+	if v, _ := mapData.Get(StrKey("Hello")); v != 1 {
+		t.Errorf("value after failed write --> %v", v)
+	}
+
+	if err := s.Delete(StrKey("Hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.get("Hello"); ok {
+		t.Error("key still in store after Delete")
+	}
+	if mapData.Len() != 0 {
+		t.Errorf("len after delete --> %v", mapData.Len())
+	}
+}
+
+func TestStoreReadThrough(t *testing.T) {
+	store := newMemStore()
+	store.data["Hello"] = "stored"
+	mapData := CreateConcurrentSliceMap(7)
+	s := mapData.BindStore(store, StoreConfig{Mode: WriteBehind, FlushInterval: time.Hour})
+	defer s.Close()
+
+	if v, ok, err := s.Get(StrKey("Hello")); v != "stored" || !ok || err != nil {
+		t.Fatalf("get --> %v, %v, %v", v, ok, err)
+	}
+	if v, ok := mapData.Get(StrKey("Hello")); !ok || v != "stored" {
+		t.Errorf("cached value --> %v, %v", v, ok)
+	}
+
+	// 未写入 Store 的删除不会从 Store 读回旧值
+	s.Delete(StrKey("Hello"))
+	if _, ok, _ := s.Get(StrKey("Hello")); ok {
+		t.Error("pending delete resurrected from store")
+	}
+	if _, ok, _ := s.Get(StrKey("missing")); ok {
+		t.Error("missing key found")
+	}
+}
+
+func TestWriteBehindCoalesce(t *testing.T) {
+	store := newMemStore()
+	s := CreateConcurrentSliceMap(7).BindStore(store, StoreConfig{Mode: WriteBehind, FlushInterval: time.Hour, MaxBatch: 10})
+
+	for i := 0; i < 100; i++ {
+		s.Set(StrKey("Hello"), i)
+	}
+	for i := int64(0); i < 25; i++ {
+		s.Set(I64Key(i), i)
+	}
+	s.Delete(I64Key(0))
+	if store.writes != 0 {
+		t.Fatalf("writes before flush --> %v", store.writes)
+	}
+	if s.Pending() != 26 {
+		t.Errorf("pending --> %v, want 26", s.Pending())
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if store.writes != 26 || len(store.batches) != 3 {
+		t.Errorf("writes --> %v, batches --> %v, want 26 in 3", store.writes, len(store.batches))
+	}
+	if v, _ := store.get("Hello"); v != 99 {
+		t.Errorf("store --> %v, want last write 99", v)
+	}
+	if _, ok := store.get(uint64(0)); ok {
+		t.Error("deleted key saved")
+	}
+	if err := s.Set(StrKey("Hello"), 0); err != ErrStoreClosed {
+		t.Errorf("set after close --> %v", err)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	store := newMemStore()
+	var mu sync.Mutex
+	var errs []error
+	s := CreateConcurrentSliceMap(7).BindStore(store, StoreConfig{
+		Mode:          WriteBehind,
+		FlushInterval: 5 * time.Millisecond,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		OnError: func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	})
+	defer s.Close()
+
+	// 第一次 flush 的三次尝试都失败，第二次 flush 的第一次重试成功
+	store.mu.Lock()
+	store.fail = 4
+	store.mu.Unlock()
+	s.Set(StrKey("Hello"), 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if v, ok := store.get("Hello"); !ok || v != 1 {
+		t.Fatalf("store --> %v, %v", v, ok)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 || errs[0] != errStore {
+		t.Errorf("errors --> %v, want one %v", errs, errStore)
+	}
+}
@@ -2,17 +2,24 @@ package HighPerformanceMap
 
 import (
 	"hash/crc64"
+	"unsafe"
 )
 
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
 // StringKey is for the string type key
 type stringKey struct {
 	key   uint64
 	value string
 }
 
+// hash 直接读取字符串的字节，避免 []byte(str) 的复制和分配
 func hash(str string) uint64 {
-	table := crc64.MakeTable(crc64.ECMA)
-	return crc64.Checksum([]byte(str), table)
+	b := *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{str, len(str)}))
+	return crc64.Checksum(b, crcTable)
 }
 
 func (s *stringKey) PartitionKey() uint64 {
@@ -23,6 +30,11 @@ func (s *stringKey) Value() any {
 	return s.value
 }
 
+func (s *stringKey) equal(stored any) bool {
+	v, ok := stored.(string)
+	return ok && v == s.value
+}
+
 func StrKey(key string) *stringKey {
 	return &stringKey{hash(key), key}
 }
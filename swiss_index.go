@@ -0,0 +1,186 @@
+package HighPerformanceMap
+
+import (
+	"math/bits"
+)
+
+// swissIndex 不使用 SIMD 的 Swiss table：每 8 个位置为一组，每组的控制字节放在一个 uint64 中，
+// 用位运算一次比较整组。控制字节为 hash 的低 7 位时表示已占用，
+// 链表头的位置直接存放在 slots 中，查找不需要再经过内置 map
+type swissIndex struct {
+	ctrl  []uint64 // 每组 8 个控制字节
+	keys  []uint64
+	slots []int
+	used  int // 已占用的位置
+	dead  int // 已删除但还不能标记为空的位置
+}
+
+const (
+	swissGroupSize = 8
+	swissEmpty     = 0x80
+	swissDeleted   = 0xFE
+
+	swissLSB = 0x0101010101010101
+	swissMSB = 0x8080808080808080
+)
+
+func newSwissIndex() *swissIndex {
+	s := &swissIndex{}
+	s.init(1)
+	return s
+}
+
+func (s *swissIndex) init(groups int) {
+	s.ctrl = make([]uint64, groups)
+	for i := range s.ctrl {
+		s.ctrl[i] = swissLSB * swissEmpty
+	}
+	s.keys = make([]uint64, groups*swissGroupSize)
+	s.slots = make([]int, groups*swissGroupSize)
+	s.used, s.dead = 0, 0
+}
+
+// swissHash 把 keyIndex 打散，高 57 位选择组，低 7 位写入控制字节
+func swissHash(keyIndex uint64) (h1 uint64, h2 uint8) {
+	h := mix64(keyIndex)
+	return h >> 7, uint8(h & 0x7F)
+}
+
+// matchH2 返回组中控制字节等于 h2 的位置，可能有误报，需要再比较 key
+func swissMatchH2(group uint64, h2 uint8) uint64 {
+	x := group ^ (swissLSB * uint64(h2))
+	return (x - swissLSB) &^ x & swissMSB
+}
+
+func swissMatchEmpty(group uint64) uint64 {
+	return group &^ (group << 6) & swissMSB
+}
+
+func swissMatchEmptyOrDeleted(group uint64) uint64 {
+	return group & swissMSB
+}
+
+// swissFirst 返回匹配结果中最低的位置并清除它
+func swissFirst(match *uint64) int {
+	i := bits.TrailingZeros64(*match) / 8
+	*match &= *match - 1
+	return i
+}
+
+func (s *swissIndex) setCtrl(pos int, c uint8) {
+	g, shift := pos/swissGroupSize, uint(pos%swissGroupSize)*8
+	s.ctrl[g] = s.ctrl[g]&^(0xFF<<shift) | uint64(c)<<shift
+}
+
+// find 按三角数序列探测各组，找到 keyIndex 所在的位置
+func (s *swissIndex) find(keyIndex uint64) (int, bool) {
+	h1, h2 := swissHash(keyIndex)
+	mask := uint64(len(s.ctrl) - 1)
+	g := h1 & mask
+	for step := uint64(1); ; step++ {
+		group := s.ctrl[g]
+		for match := swissMatchH2(group, h2); match != 0; {
+			pos := int(g)*swissGroupSize + swissFirst(&match)
+			if s.keys[pos] == keyIndex {
+				return pos, true
+			}
+		}
+		if swissMatchEmpty(group) != 0 {
+			return -1, false
+		}
+		g = (g + step) & mask
+	}
+}
+
+func (s *swissIndex) get(keyIndex uint64) (int, bool) {
+	if pos, ok := s.find(keyIndex); ok {
+		return s.slots[pos], true
+	}
+	return 0, false
+}
+
+func (s *swissIndex) set(keyIndex uint64, index int) {
+	if pos, ok := s.find(keyIndex); ok {
+		s.slots[pos] = index
+		return
+	}
+
+	// 占用和删除的位置超过 7/8 时重建，删除的位置多时大小不变
+	if (s.used+s.dead+1)*8 > len(s.keys)*7 {
+		groups := len(s.ctrl)
+		if s.used*2 >= len(s.keys)*7/8 {
+			groups *= 2
+		}
+		s.rehash(groups)
+	}
+	s.insert(keyIndex, index)
+}
+
+// insert 把不存在的 keyIndex 放到探测序列中第一个空的或已删除的位置
+func (s *swissIndex) insert(keyIndex uint64, index int) {
+	h1, h2 := swissHash(keyIndex)
+	mask := uint64(len(s.ctrl) - 1)
+	g := h1 & mask
+	for step := uint64(1); ; step++ {
+		if match := swissMatchEmptyOrDeleted(s.ctrl[g]); match != 0 {
+			pos := int(g)*swissGroupSize + swissFirst(&match)
+			if uint8(s.ctrl[g]>>(uint(pos%swissGroupSize)*8)) == swissDeleted {
+				s.dead--
+			}
+			s.setCtrl(pos, h2)
+			s.keys[pos] = keyIndex
+			s.slots[pos] = index
+			s.used++
+			return
+		}
+		g = (g + step) & mask
+	}
+}
+
+func (s *swissIndex) rehash(groups int) {
+	ctrl, keys, slots := s.ctrl, s.keys, s.slots
+	s.init(groups)
+	for g, group := range ctrl {
+		for match := group&swissMSB ^ swissMSB; match != 0; {
+			pos := g*swissGroupSize + swissFirst(&match)
+			s.insert(keys[pos], slots[pos])
+		}
+	}
+}
+
+// delete 所在组还有空位时，查找不会越过这一组，可以直接标记为空
+func (s *swissIndex) delete(keyIndex uint64) {
+	pos, ok := s.find(keyIndex)
+	if !ok {
+		return
+	}
+	if swissMatchEmpty(s.ctrl[pos/swissGroupSize]) != 0 {
+		s.setCtrl(pos, swissEmpty)
+	} else {
+		s.setCtrl(pos, swissDeleted)
+		s.dead++
+	}
+	s.used--
+}
+
+func (s *swissIndex) len() int {
+	return s.used
+}
+
+func (s *swissIndex) rangeIndex(f func(keyIndex uint64, index int) bool) {
+	s.rangeFrom(0, f)
+}
+
+// rangeFrom 从第 start % 组数 组开始遍历，到最后一组之后回到第 0 组
+func (s *swissIndex) rangeFrom(start uint64, f func(keyIndex uint64, index int) bool) {
+	first := int(start % uint64(len(s.ctrl)))
+	for i := range s.ctrl {
+		g := (first + i) % len(s.ctrl)
+		for match := s.ctrl[g]&swissMSB ^ swissMSB; match != 0; {
+			pos := g*swissGroupSize + swissFirst(&match)
+			if !f(s.keys[pos], s.slots[pos]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package HighPerformanceMap
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestSwissIndex(t *testing.T) {
+	s := newSwissIndex()
+	want := make(map[uint64]int)
+	r := rand.New(rand.NewSource(1))
+
+	// key 范围小，反复删除和写入以覆盖已删除位置的复用和原地重建
+	for i := 0; i < 200000; i++ {
+		keyIndex := uint64(r.Intn(3000))
+		switch r.Intn(3) {
+		case 0:
+			s.set(keyIndex, i)
+			want[keyIndex] = i
+		case 1:
+			s.delete(keyIndex)
+			delete(want, keyIndex)
+		default:
+			index, ok := s.get(keyIndex)
+			if w, wok := want[keyIndex]; ok != wok || ok && index != w {
+				t.Fatalf("get %v --> %v, %v, want %v, %v", keyIndex, index, ok, w, wok)
+			}
+		}
+	}
+
+	if s.len() != len(want) {
+		t.Errorf("len --> %v, want %v", s.len(), len(want))
+	}
+	n := 0
+	s.rangeIndex(func(keyIndex uint64, index int) bool {
+		n++
+		if want[keyIndex] != index {
+			t.Errorf("range %v --> %v", keyIndex, index)
+		}
+		return true
+	})
+	if n != len(want) {
+		t.Errorf("range --> %v", n)
+	}
+}
+
+func TestSwissTableBackend(t *testing.T) {
+	num := 10000
+	mapData := CreateConcurrentSliceMapWithBackend(4, SwissTableBackend)
+	mapData.SetResizePolicy(64, 1024)
+	for i := 0; i < num; i++ {
+		mapData.Set(StrKey(strconv.Itoa(i)), i)
+	}
+	for i := 0; i < num; i += 2 {
+		mapData.Delete(StrKey(strconv.Itoa(i)))
+	}
+	mapData.EnableLockFreeReads()
+	mapData.Resize(8)
+
+	for i := 0; i < num; i++ {
+		v, ok := mapData.GetString(strconv.Itoa(i))
+		if i%2 == 0 && ok || i%2 == 1 && (!ok || v.(int) != i) {
+			t.Fatalf("get %v --> %v, %v", i, v, ok)
+		}
+	}
+	if mapData.Len() != num/2 {
+		t.Errorf("len --> %v", mapData.Len())
+	}
+}
+
+func benchmarkPartitionBackend(b *testing.B, backend PartitionBackend) {
+	num := 100000
+	mapData := CreateConcurrentSliceMapWithBackend(16, backend)
+	for i := 0; i < num; i++ {
+		mapData.Set(I64Key(int64(i)), i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			mapData.GetInt64(int64(i % num))
+			i++
+		}
+	})
+}
+
+func BenchmarkPartitionBackendGet(b *testing.B) {
+	b.Run("builtin", func(b *testing.B) {
+		benchmarkPartitionBackend(b, BuiltinMapBackend)
+	})
+	b.Run("swiss", func(b *testing.B) {
+		benchmarkPartitionBackend(b, SwissTableBackend)
+	})
+	b.Run("cuckoo", func(b *testing.B) {
+		benchmarkPartitionBackend(b, CuckooBackend)
+	})
+}
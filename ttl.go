@@ -0,0 +1,114 @@
+package HighPerformanceMap
+
+import (
+	"time"
+)
+
+// ttlValue 带过期时间的值，只在 SetWithTTL 时使用，其它值不需要额外的内存
+type ttlValue struct {
+	value    any
+	expireAt int64 // UnixNano
+}
+
+// SetClock replaces the clock used to expire entries, which is time.Now by
+// default. It must be called before the map is used.
+func (m *concurrentMap) SetClock(now func() time.Time) {
+	m.clock = now
+}
+
+func (m *concurrentMap) now() int64 {
+	if m.clock != nil {
+		return m.clock().UnixNano()
+	}
+	return time.Now().UnixNano()
+}
+
+// SetWithTTL sets key like Set, but the entry expires after ttl. Expired
+// entries are never returned; they are removed when Get finds them or by
+// DeleteExpired, and watchers receive EventExpire.
+func (m *concurrentMap) SetWithTTL(key Partitionable, v any, ttl time.Duration) {
+	m.set(key, v, &ttlValue{value: v, expireAt: m.now() + int64(ttl)})
+}
+
+func (m *concurrentMap) expired(data *innerSlice) bool {
+	t, ok := (*(*any)(data.Value)).(*ttlValue)
+	return ok && t.expireAt <= m.now()
+}
+
+// liveValue 返回 data 的值，data 已过期时删除它，data 为 nil 表示不存在
+func (m *concurrentMap) liveValue(keyIndex uint64, data *innerSlice) (any, bool) {
+	if data == nil {
+		return nil, false
+	}
+	value := *(*any)(data.Value)
+	t, ok := value.(*ttlValue)
+	if !ok {
+		return value, true
+	}
+	if t.expireAt > m.now() {
+		return t.value, true
+	}
+	m.expire(keyIndex, data)
+	return nil, false
+}
+
+// expire 删除已过期的 data，data 已被覆盖或删除时不做任何事，返回是否删除
+func (m *concurrentMap) expire(keyIndex uint64, data *innerSlice) bool {
+	m.mu.RLock()
+	p := m.lockPartition(keyIndex, true)
+	index, prev, ok := p.lookupFunc(keyIndex, func(stored any) bool {
+		return stored == data.key
+	})
+	ok = ok && p.innerSlice[index] == data
+	if ok {
+		m.removeLocked(p, keyIndex, index, prev)
+		if m.watchers.active() {
+			m.watchers.enqueue(keyIndex, Event{Type: EventExpire, Key: data.key, OldValue: m.getValue(data.Value)})
+		}
+	}
+	p.mu.Unlock()
+	m.mu.RUnlock()
+	m.watchers.deliver()
+	m.rehash()
+	return ok
+}
+
+// DeleteExpired removes every expired entry, including the ones spilled to
+// the disk tier, and returns how many were removed.
+func (m *concurrentMap) DeleteExpired() int {
+	type expiredEntry struct {
+		keyIndex uint64
+		data     *innerSlice
+	}
+	var entries []expiredEntry
+
+	m.mu.RLock()
+	for _, partitions := range [][]*partition{m.oldPartitions, m.partitions} {
+		for _, p := range partitions {
+			p.mu.RLock()
+			if !p.migrated {
+				p.index.rangeIndex(func(keyIndex uint64, index int) bool {
+					for ; index >= 0; index = int(p.innerSlice[index].next) {
+						if data := p.innerSlice[index]; m.expired(data) {
+							entries = append(entries, expiredEntry{keyIndex, data})
+						}
+					}
+					return true
+				})
+			}
+			p.mu.RUnlock()
+		}
+	}
+	m.mu.RUnlock()
+
+	removed := 0
+	for _, e := range entries {
+		if m.expire(e.keyIndex, e.data) {
+			removed++
+		}
+	}
+	if m.disk != nil {
+		removed += m.deleteExpiredSpilled()
+	}
+	return removed
+}
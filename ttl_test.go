@@ -0,0 +1,177 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	mapData.SetWithTTL(StrKey("short"), 1, 20*time.Millisecond)
+	mapData.SetWithTTL(StrKey("long"), 2, time.Hour)
+	mapData.Set(StrKey("forever"), 3)
+
+	if v, ok := mapData.Get(StrKey("short")); !ok || v != 1 {
+		t.Fatalf("get short --> %v, %v", v, ok)
+	}
+
+	clock.Advance(40 * time.Millisecond)
+	if _, ok := mapData.Get(StrKey("short")); ok {
+		t.Error("expired key still returned")
+	}
+	if mapData.Len() != 2 {
+		t.Errorf("len after lazy expiry --> %v, want 2", mapData.Len())
+	}
+	if v, ok := mapData.Get(StrKey("long")); !ok || v != 2 {
+		t.Errorf("get long --> %v, %v", v, ok)
+	}
+
+	// 覆盖为不过期的值
+	mapData.SetWithTTL(StrKey("short"), 4, 20*time.Millisecond)
+	mapData.Set(StrKey("short"), 5)
+	clock.Advance(40 * time.Millisecond)
+	if v, ok := mapData.Get(StrKey("short")); !ok || v != 5 {
+		t.Errorf("get short after set without TTL --> %v, %v", v, ok)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	ch, cancel := mapData.Subscribe(WithBufferSize(200))
+	defer cancel()
+
+	for i := int64(0); i < 100; i++ {
+		ttl := time.Hour
+		if i%2 == 0 {
+			ttl = 10 * time.Millisecond
+		}
+		mapData.SetWithTTL(I64Key(i), i, ttl)
+	}
+	clock.Advance(30 * time.Millisecond)
+
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		if value.(int64)%2 == 0 {
+			t.Errorf("expired key in range --> %v", key)
+		}
+		n++
+		return true
+	})
+	if n != 50 {
+		t.Errorf("range --> %v, want 50", n)
+	}
+
+	if removed := mapData.DeleteExpired(); removed != 50 {
+		t.Errorf("expired --> %v, want 50", removed)
+	}
+	if mapData.Len() != 50 {
+		t.Errorf("len --> %v, want 50", mapData.Len())
+	}
+
+	expired := 0
+	for len(ch) > 0 {
+		if ev := <-ch; ev.Type == EventExpire {
+			expired++
+		}
+	}
+	if expired != 50 {
+		t.Errorf("expire events --> %v, want 50", expired)
+	}
+}
+
+func TestTTLLockFreeReads(t *testing.T) {
+	clock := newFakeClock()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	mapData.EnableLockFreeReads()
+	mapData.SetWithTTL(StrKey("Hello"), 1, 10*time.Millisecond)
+	if v, ok := mapData.Get(StrKey("Hello")); !ok || v != 1 {
+		t.Fatalf("get --> %v, %v", v, ok)
+	}
+	clock.Advance(30 * time.Millisecond)
+	if _, ok := mapData.Get(StrKey("Hello")); ok {
+		t.Error("expired key returned by lock-free read")
+	}
+	if mapData.Len() != 0 {
+		t.Errorf("len --> %v, want 0", mapData.Len())
+	}
+}
+
+// fakeClock 测试中手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestSetClock(t *testing.T) {
+	clock := newFakeClock()
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.SetClock(clock.Now)
+	mapData.SetWithTTL(StrKey("Hello"), 1, time.Minute)
+
+	clock.Advance(time.Minute - time.Nanosecond)
+	if v, ok := mapData.Get(StrKey("Hello")); !ok || v != 1 {
+		t.Fatalf("get before expiry --> %v, %v", v, ok)
+	}
+	clock.Advance(time.Nanosecond)
+	if _, ok := mapData.Get(StrKey("Hello")); ok {
+		t.Error("key returned at its expiry time")
+	}
+}
+
+func TestTTLSecondaryIndexes(t *testing.T) {
+	clock := newFakeClock()
+	ordered := CreateOrderedSliceMap(7)
+	ordered.SetClock(clock.Now)
+	ordered.EnablePrefixIndex()
+	ordered.SetWithTTL(I64Key(1), 1, time.Minute)
+	ordered.Set(I64Key(2), 2)
+	ordered.SetWithTTL(StrKey("user:1"), 1, time.Minute)
+	ordered.Set(StrKey("user:2"), 2)
+	clock.Advance(time.Minute)
+
+	if k, _, ok := ordered.Min(); !ok || k != 2 {
+		t.Errorf("min --> %v, %v", k, ok)
+	}
+	if _, _, ok := ordered.Floor(1); ok {
+		t.Error("Floor(1) returned an expired key")
+	}
+	n := 0
+	ordered.Ascend(func(key int64, value any) bool {
+		n++
+		return true
+	})
+	if n != 1 {
+		t.Errorf("ascend --> %v, want 1", n)
+	}
+
+	var keys []string
+	ordered.ScanPrefix("user:", func(key string, value any) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) != 1 || keys[0] != "user:2" {
+		t.Errorf("prefix keys --> %v", keys)
+	}
+}
@@ -0,0 +1,219 @@
+package HighPerformanceMap
+
+import (
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Txn is a transaction of concurrentMap.Txn. Reads see the value committed
+// when the key was first read in the transaction, or the transaction's own
+// writes; writes are buffered until commit.
+type Txn struct {
+	m       *concurrentMap
+	entries map[any]*txnEntry // key.Value() → 读写过的 key
+}
+
+// txnEntry 事务中一个 key 的读写记录
+type txnEntry struct {
+	key      Partitionable
+	keyIndex uint64
+	read     bool
+	data     *innerSlice // 第一次读取时的值，nil 表示不存在，提交时检查是否被修改
+	write    bool
+	deleted  bool
+	value    any
+}
+
+// Txn runs f in a transaction. If f returns an error, its writes are
+// discarded and the error is returned. Otherwise the keys f read are
+// checked and its writes are applied atomically with the partitions of all
+// the keys locked in a fixed order; if another write changed a key f read,
+// f runs again, so f must not have side effects outside tx. Transactions
+// and locked reads never observe part of a commit, reads enabled by
+// EnableLockFreeReads may.
+func (m *concurrentMap) Txn(f func(tx *Txn) error) error {
+	for {
+		tx := &Txn{m: m, entries: make(map[any]*txnEntry)}
+		if err := f(tx); err != nil {
+			return err
+		}
+		if tx.commit() {
+			return nil
+		}
+		runtime.Gosched()
+	}
+}
+
+func (tx *Txn) entry(key Partitionable) *txnEntry {
+	k := key.Value()
+	e := tx.entries[k]
+	if e == nil {
+		e = &txnEntry{key: key, keyIndex: key.PartitionKey()}
+		tx.entries[k] = e
+	}
+	return e
+}
+
+// lookup 返回 key 当前在内存中的值，调用方持有分区的锁
+func (tx *Txn) lookup(p *partition, e *txnEntry) *innerSlice {
+	if index, _, ok := p.lookup(e.keyIndex, e.key); ok {
+		return p.innerSlice[index]
+	}
+	return nil
+}
+
+func (tx *Txn) Get(key Partitionable) (any, bool) {
+	e := tx.entry(key)
+	switch {
+	case e.write:
+		return e.value, !e.deleted
+	case !e.read:
+		m := tx.m
+		// 磁盘上的 key 先移回内存
+		if m.disk != nil {
+			m.getFunc(e.keyIndex, func(stored any) bool {
+				return keyEqual(key, stored)
+			})
+		}
+		m.mu.RLock()
+		p := m.lockPartition(e.keyIndex, false)
+		e.data = tx.lookup(p, e)
+		p.mu.RUnlock()
+		m.mu.RUnlock()
+		e.read = true
+	}
+
+	if e.data == nil || tx.m.expired(e.data) {
+		return nil, false
+	}
+	return tx.m.getValue(e.data.Value), true
+}
+
+func (tx *Txn) Set(key Partitionable, v any) {
+	e := tx.entry(key)
+	e.write, e.deleted, e.value = true, false, v
+}
+
+func (tx *Txn) Delete(key Partitionable) {
+	e := tx.entry(key)
+	e.write, e.deleted, e.value = true, true, nil
+}
+
+// txnPartition 提交时需要加锁的分区，rank 为加锁的顺序：旧分区表在前，新分区表在后
+type txnPartition struct {
+	p       *partition
+	rank    int
+	write   bool
+	entries []*txnEntry
+}
+
+// commit 按 rank 的顺序锁住所有分区，检查读取过的 key 没有被修改后写入，返回是否成功
+func (tx *Txn) commit() bool {
+	if len(tx.entries) == 0 {
+		return true
+	}
+	m := tx.m
+
+	m.mu.RLock()
+	// 暂停迁移，key 所在的分区在提交期间不变。不同的事务和遍历可以同时持有读锁
+	m.rehashMu.RLock()
+	byPartition := make(map[*partition]*txnPartition)
+	for _, e := range tx.entries {
+		rank := int(e.keyIndex % uint64(m.lenOfBucket))
+		p := m.partitions[rank]
+		if old := m.oldPartitions; old != nil {
+			rank += len(old)
+			if i := int(e.keyIndex % uint64(len(old))); !old[i].migrated {
+				p, rank = old[i], i
+			}
+		}
+		tp := byPartition[p]
+		if tp == nil {
+			tp = &txnPartition{p: p, rank: rank}
+			byPartition[p] = tp
+		}
+		tp.write = tp.write || e.write
+		tp.entries = append(tp.entries, e)
+	}
+	partitions := make([]*txnPartition, 0, len(byPartition))
+	for _, tp := range byPartition {
+		partitions = append(partitions, tp)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].rank < partitions[j].rank
+	})
+
+	for _, tp := range partitions {
+		tp.p.lock(tp.write)
+	}
+	valid := true
+	for _, tp := range partitions {
+		for _, e := range tp.entries {
+			if e.read && tx.lookup(tp.p, e) != e.data {
+				valid = false
+			}
+		}
+	}
+	if valid {
+		for _, tp := range partitions {
+			for _, e := range tp.entries {
+				if !e.write {
+					continue
+				}
+				if ev, ok := tx.apply(tp.p, e); ok && m.watchers.active() {
+					m.watchers.enqueue(e.keyIndex, ev)
+				}
+			}
+		}
+	}
+	for _, tp := range partitions {
+		tp.p.unlock(tp.write)
+	}
+	m.rehashMu.RUnlock()
+	m.mu.RUnlock()
+
+	if !valid {
+		return false
+	}
+	m.watchers.deliver()
+	m.rehash()
+	m.evict()
+	return true
+}
+
+// apply 写入一个 key，与 Set、Delete 相同地更新附加索引、权重、计数和磁盘层，
+// 返回需要发布的事件，调用方持有分区的写锁
+func (tx *Txn) apply(p *partition, e *txnEntry) (Event, bool) {
+	m := tx.m
+	if e.deleted {
+		var data *innerSlice
+		if index, prev, ok := p.lookup(e.keyIndex, e.key); ok {
+			data = m.removeLocked(p, e.keyIndex, index, prev)
+		} else if m.disk != nil {
+			data = m.takeSpilled(e.keyIndex, func(stored any) bool {
+				return keyEqual(e.key, stored)
+			})
+		}
+		if data == nil {
+			return Event{}, false
+		}
+		return Event{Type: EventDelete, Key: data.key, OldValue: m.getValue(data.Value)}, true
+	}
+
+	var stored any = e.value
+	data := &innerSlice{key: e.key.Value(), Value: unsafe.Pointer(&stored)}
+	old := p.set(e.keyIndex, e.key, data)
+	m.setLocked(p, e.keyIndex, data, old)
+	ev := Event{Type: EventSet, Key: data.key, NewValue: e.value}
+	if old == nil {
+		atomic.AddInt64(&m.count, 1)
+		if m.disk != nil {
+			m.disk.remove(e.keyIndex, setKey{e.keyIndex, data.key}.equal)
+		}
+	} else {
+		ev.OldValue = m.getValue(old)
+	}
+	return ev, true
+}
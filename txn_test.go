@@ -0,0 +1,187 @@
+package HighPerformanceMap
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTxnCommitAndRollback(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.Set(StrKey("a"), 100)
+	mapData.Set(StrKey("b"), 0)
+
+	err := mapData.Txn(func(tx *Txn) error {
+		a, _ := tx.Get(StrKey("a"))
+		b, _ := tx.Get(StrKey("b"))
+		tx.Set(StrKey("a"), a.(int)-30)
+		tx.Set(StrKey("b"), b.(int)+30)
+		tx.Set(StrKey("c"), "new")
+		tx.Delete(StrKey("c"))
+		if _, ok := tx.Get(StrKey("c")); ok {
+			t.Error("deleted key visible in its transaction")
+		}
+		if v, _ := tx.Get(StrKey("a")); v != 70 {
+			t.Errorf("transaction read --> %v, want its own write 70", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := mapData.Get(StrKey("a")); a != 70 {
+		t.Errorf("a --> %v, want 70", a)
+	}
+	if b, _ := mapData.Get(StrKey("b")); b != 30 {
+		t.Errorf("b --> %v, want 30", b)
+	}
+	if mapData.Len() != 2 {
+		t.Errorf("len --> %v, want 2", mapData.Len())
+	}
+
+	errAbort := errors.New("abort")
+	err = mapData.Txn(func(tx *Txn) error {
+		tx.Set(StrKey("a"), 0)
+		tx.Delete(StrKey("b"))
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("txn err --> %v, want %v", err, errAbort)
+	}
+	if a, _ := mapData.Get(StrKey("a")); a != 70 {
+		t.Errorf("a after rollback --> %v", a)
+	}
+	if _, ok := mapData.Get(StrKey("b")); !ok {
+		t.Error("b deleted by a rolled back transaction")
+	}
+}
+
+func TestTxnEvents(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.Set(StrKey("a"), 1)
+	ch, cancel := mapData.Subscribe()
+	defer cancel()
+
+	mapData.Txn(func(tx *Txn) error {
+		tx.Set(StrKey("a"), 2)
+		tx.Delete(StrKey("missing"))
+		return nil
+	})
+	if ev := <-ch; ev != (Event{Type: EventSet, Key: "a", OldValue: 1, NewValue: 2, Seq: 1}) {
+		t.Errorf("event --> %+v", ev)
+	}
+	if len(ch) != 0 {
+		t.Errorf("unexpected events --> %v", len(ch))
+	}
+}
+
+// 并发转账，所有账户的总额不变，每个事务读到的总额也不变
+func TestTxnConservedTotal(t *testing.T) {
+	const accounts, initial = 16, 1000
+	mapData := CreateConcurrentSliceMap(4)
+	mapData.SetResizePolicy(1, 2)
+	for i := int64(0); i < accounts; i++ {
+		mapData.Set(I64Key(i), initial)
+	}
+
+	total := func(tx *Txn) int {
+		sum := 0
+		for i := int64(0); i < accounts; i++ {
+			v, _ := tx.Get(I64Key(i))
+			sum += v.(int)
+		}
+		return sum
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				from, to := I64Key(r.Int63n(accounts)), I64Key(r.Int63n(accounts))
+				amount := r.Intn(50)
+				mapData.Txn(func(tx *Txn) error {
+					a, _ := tx.Get(from)
+					if a.(int) < amount {
+						return errors.New("insufficient funds")
+					}
+					tx.Set(from, a.(int)-amount)
+					b, _ := tx.Get(to)
+					tx.Set(to, b.(int)+amount)
+					return nil
+				})
+			}
+		}(int64(g))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			// 提交失败的执行可能读到不一致的值，只检查最后一次执行
+			var sum int
+			mapData.Txn(func(tx *Txn) error {
+				sum = total(tx)
+				return nil
+			})
+			if sum != accounts*initial {
+				t.Errorf("transaction total --> %v, want %v", sum, accounts*initial)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	var sum int
+	mapData.Txn(func(tx *Txn) error {
+		sum = total(tx)
+		return nil
+	})
+	if sum != accounts*initial {
+		t.Errorf("total --> %v, want %v", sum, accounts*initial)
+	}
+}
+
+func TestTxnDuringRange(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(7)
+	mapData.Set(I64Key(0), 0)
+
+	// I64Key 的 hash 就是 key，0、1、2 在不同的分区。遍历的回调中和其它 goroutine
+	// 提交的事务都不需要等待遍历结束
+	n := 0
+	mapData.Range(func(key, value any) bool {
+		if key != uint64(0) {
+			return true
+		}
+		n++
+		if err := mapData.Txn(func(tx *Txn) error {
+			tx.Set(I64Key(1), 1)
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+
+		done := make(chan error)
+		go func() {
+			done <- mapData.Txn(func(tx *Txn) error {
+				tx.Set(I64Key(2), 2)
+				return nil
+			})
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("transaction blocked by Range")
+		}
+		return true
+	})
+	if n != 1 || mapData.Len() != 3 {
+		t.Errorf("callbacks --> %v, len --> %v", n, mapData.Len())
+	}
+}
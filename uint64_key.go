@@ -0,0 +1,23 @@
+package HighPerformanceMap
+
+// uint64Key 与 I64Key 保存相同的 uint64，相同的位表示同一个 key
+type uint64Key struct {
+	value uint64
+}
+
+func (u *uint64Key) PartitionKey() uint64 {
+	return u.value
+}
+
+func (u *uint64Key) Value() any {
+	return u.value
+}
+
+func (u *uint64Key) equal(stored any) bool {
+	v, ok := stored.(uint64)
+	return ok && v == u.value
+}
+
+func U64Key(key uint64) *uint64Key {
+	return &uint64Key{key}
+}
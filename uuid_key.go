@@ -0,0 +1,29 @@
+package HighPerformanceMap
+
+import (
+	"encoding/binary"
+)
+
+type uuidKey struct {
+	key   uint64
+	value [16]byte
+}
+
+func (u *uuidKey) PartitionKey() uint64 {
+	return u.key
+}
+
+func (u *uuidKey) Value() any {
+	return u.value
+}
+
+func (u *uuidKey) equal(stored any) bool {
+	v, ok := stored.([16]byte)
+	return ok && v == u.value
+}
+
+func UUIDKey(key [16]byte) *uuidKey {
+	hi := binary.BigEndian.Uint64(key[:8])
+	lo := binary.BigEndian.Uint64(key[8:])
+	return &uuidKey{combineHash(hi, lo), key}
+}
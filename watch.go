@@ -0,0 +1,253 @@
+package HighPerformanceMap
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// EventType 变更事件的类型
+type EventType int
+
+const (
+	EventSet    EventType = iota + 1 // 写入或覆盖
+	EventDelete                      // 调用 Delete 删除
+	EventExpire                      // 过期删除
+	EventEvict                       // 容量不足被淘汰
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// Event describes one change of the map. Key is the same value Range passes
+// to its callback; OldValue is nil when the key did not exist before. Seq
+// numbers the events in the order the changes were applied, and events are
+// delivered in Seq order, so the last event of a key holds its current value.
+type Event struct {
+	Type     EventType
+	Key      any
+	OldValue any
+	NewValue any
+	Seq      uint64
+}
+
+// SlowConsumerPolicy 订阅者缓冲区满时的处理方式
+type SlowConsumerPolicy int
+
+const (
+	DropEvents  SlowConsumerPolicy = iota // 丢弃新事件
+	BlockWriter                           // 阻塞写入方直到订阅者读取，事件按顺序发送，订阅者阻塞时其它写入方也会阻塞
+	Disconnect                            // 关闭订阅者的 channel
+)
+
+const defaultWatchBuffer = 64
+
+type WatchOption func(*subscriber)
+
+// WithBufferSize sets how many undelivered events a subscriber may hold.
+func WithBufferSize(n int) WatchOption {
+	return func(s *subscriber) {
+		s.size = n
+	}
+}
+
+// WithSlowConsumerPolicy sets what happens when the subscriber buffer is full.
+func WithSlowConsumerPolicy(policy SlowConsumerPolicy) WatchOption {
+	return func(s *subscriber) {
+		s.policy = policy
+	}
+}
+
+type subscriber struct {
+	hub    *watchHub
+	match  func(hash uint64, key any) bool // nil 表示接收全部事件
+	size   int
+	policy SlowConsumerPolicy
+
+	ch     chan Event
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex // 保证 close(ch) 之后不再发送
+	closed bool
+}
+
+func (s *subscriber) send(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	switch s.policy {
+	case BlockWriter:
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+	case Disconnect:
+		s.once.Do(func() { close(s.done) })
+		s.closed = true
+		close(s.ch)
+		s.hub.remove(s)
+	}
+}
+
+func (s *subscriber) cancel() {
+	// 先关闭 done，让阻塞在 send 中的写入方释放 s.mu
+	s.once.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+	s.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// watchHub 保存所有订阅者，写时复制，发布事件时不加锁。写入方在分区的锁内把事件加入
+// queue 并分配序号，释放锁之后调用 deliver 按序号发送，同一个 key 的事件顺序与写入顺序相同
+type watchHub struct {
+	mu   sync.Mutex
+	subs atomic.Value // []*subscriber
+
+	queueMu sync.Mutex
+	queue   []queuedEvent // 未发送的事件，按序号排列
+	seq     uint64        // 最后分配的序号，由 queueMu 保护
+	queued  int64         // queue 的长度，原子操作，没有事件时 deliver 不加锁
+
+	deliverMu sync.Mutex // 同一时间只有一个写入方发送事件
+}
+
+type queuedEvent struct {
+	hash uint64
+	ev   Event
+}
+
+func (h *watchHub) load() []*subscriber {
+	subs, _ := h.subs.Load().([]*subscriber)
+	return subs
+}
+
+func (h *watchHub) active() bool {
+	return len(h.load()) > 0
+}
+
+func (h *watchHub) add(match func(hash uint64, key any) bool, opts []WatchOption) (<-chan Event, func()) {
+	s := &subscriber{
+		hub:   h,
+		match: match,
+		size:  defaultWatchBuffer,
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ch = make(chan Event, s.size)
+
+	h.mu.Lock()
+	old := h.load()
+	subs := make([]*subscriber, len(old), len(old)+1)
+	copy(subs, old)
+	h.subs.Store(append(subs, s))
+	h.mu.Unlock()
+
+	return s.ch, s.cancel
+}
+
+func (h *watchHub) remove(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old := h.load()
+	subs := make([]*subscriber, 0, len(old))
+	for _, sub := range old {
+		if sub != s {
+			subs = append(subs, sub)
+		}
+	}
+	h.subs.Store(subs)
+}
+
+// enqueue 为事件分配序号并加入队列，调用方持有 key 所在分区的写锁
+func (h *watchHub) enqueue(hash uint64, ev Event) {
+	h.queueMu.Lock()
+	h.seq++
+	ev.Seq = h.seq
+	h.queue = append(h.queue, queuedEvent{hash, ev})
+	atomic.AddInt64(&h.queued, 1)
+	h.queueMu.Unlock()
+}
+
+// deliver 按序号发送队列中的事件，返回时调用方加入的事件都已发送，
+// 必须在释放 map 的锁之后调用
+func (h *watchHub) deliver() {
+	if atomic.LoadInt64(&h.queued) == 0 {
+		return
+	}
+	h.deliverMu.Lock()
+	defer h.deliverMu.Unlock()
+
+	for {
+		h.queueMu.Lock()
+		queue := h.queue
+		h.queue = nil
+		atomic.AddInt64(&h.queued, -int64(len(queue)))
+		h.queueMu.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+
+		for _, q := range queue {
+			h.publish(q.hash, q.ev)
+		}
+	}
+}
+
+func (h *watchHub) publish(hash uint64, ev Event) {
+	for _, s := range h.load() {
+		if s.match == nil || s.match(hash, ev.Key) {
+			s.send(ev)
+		}
+	}
+}
+
+// Watch returns a channel receiving the events of a single key. The returned
+// function stops the watch and closes the channel.
+func (m *concurrentMap) Watch(key Partitionable, opts ...WatchOption) (<-chan Event, func()) {
+	keyIndex, value := key.PartitionKey(), key.Value()
+	return m.watchers.add(func(hash uint64, key any) bool {
+		return hash == keyIndex && key == value
+	}, opts)
+}
+
+// WatchPrefix returns a channel receiving the events of every string key
+// starting with prefix.
+func (m *concurrentMap) WatchPrefix(prefix string, opts ...WatchOption) (<-chan Event, func()) {
+	return m.watchers.add(func(_ uint64, key any) bool {
+		s, ok := key.(string)
+		return ok && strings.HasPrefix(s, prefix)
+	}, opts)
+}
+
+// Subscribe returns a channel receiving every event of the map.
+func (m *concurrentMap) Subscribe(opts ...WatchOption) (<-chan Event, func()) {
+	return m.watchers.add(nil, opts)
+}
@@ -0,0 +1,134 @@
+package HighPerformanceMap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchKey(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	ch, cancel := mapData.Watch(StrKey("Hello"))
+
+	mapData.Set(StrKey("Hello"), 1)
+	mapData.Set(StrKey("World"), 2)
+	mapData.Set(StrKey("Hello"), 3)
+	mapData.Delete(StrKey("Hello"))
+
+	want := []Event{
+		{Type: EventSet, Key: "Hello", NewValue: 1},
+		{Type: EventSet, Key: "Hello", OldValue: 1, NewValue: 3},
+		{Type: EventDelete, Key: "Hello", OldValue: 3},
+	}
+	var seq uint64
+	for _, w := range want {
+		ev := <-ch
+		if ev.Seq <= seq {
+			t.Errorf("seq --> %v after %v", ev.Seq, seq)
+		}
+		seq, ev.Seq = ev.Seq, 0
+		if ev != w {
+			t.Errorf("got %+v, want %+v", ev, w)
+		}
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after cancel")
+	}
+	mapData.Set(StrKey("Hello"), 4)
+}
+
+func TestWatchPrefixAndSubscribe(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	prefixCh, cancelPrefix := mapData.WatchPrefix("tenant:42:")
+	defer cancelPrefix()
+	allCh, cancelAll := mapData.Subscribe()
+	defer cancelAll()
+
+	mapData.Set(StrKey("tenant:42:user:7"), 1)
+	mapData.Set(StrKey("tenant:43:user:7"), 2)
+	mapData.Set(I64Key(42), 3)
+
+	if ev := <-prefixCh; ev.Key != "tenant:42:user:7" {
+		t.Errorf("prefix watch got %+v", ev)
+	}
+	if len(prefixCh) != 0 {
+		t.Error("prefix watch got unrelated events")
+	}
+	if len(allCh) != 3 {
+		t.Errorf("subscribe got %v events", len(allCh))
+	}
+}
+
+func TestSlowConsumerPolicy(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+
+	dropCh, cancelDrop := mapData.Subscribe(WithBufferSize(1))
+	defer cancelDrop()
+	closeCh, cancelClose := mapData.Subscribe(WithBufferSize(1), WithSlowConsumerPolicy(Disconnect))
+	defer cancelClose()
+
+	mapData.Set(I64Key(1), 1)
+	mapData.Set(I64Key(2), 2)
+
+	if ev := <-dropCh; ev.NewValue != 1 || len(dropCh) != 0 {
+		t.Errorf("drop policy got %+v", ev)
+	}
+	<-closeCh
+	if _, ok := <-closeCh; ok {
+		t.Error("slow consumer not disconnected")
+	}
+
+	blockCh, cancelBlock := mapData.Subscribe(WithBufferSize(1), WithSlowConsumerPolicy(BlockWriter))
+	defer cancelBlock()
+	mapData.Set(I64Key(3), 3)
+
+	done := make(chan struct{})
+	go func() {
+		mapData.Set(I64Key(4), 4)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("writer not blocked by slow consumer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-blockCh
+	<-done
+	if ev := <-blockCh; ev.NewValue != 4 {
+		t.Errorf("block policy got %+v", ev)
+	}
+}
+
+func TestWatchOrder(t *testing.T) {
+	mapData := CreateConcurrentSliceMap(99)
+	ch, cancel := mapData.Watch(StrKey("config"), WithBufferSize(1<<16))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mapData.Set(StrKey("config"), i*1000+j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var last Event
+	for i := 0; i < 8000; i++ {
+		ev := <-ch
+		if ev.Seq <= last.Seq || (i > 0 && ev.OldValue != last.NewValue) {
+			t.Fatalf("event %+v after %+v", ev, last)
+		}
+		last = ev
+	}
+	if v, _ := mapData.Get(StrKey("config")); last.NewValue != v {
+		t.Errorf("last event --> %v, value --> %v", last.NewValue, v)
+	}
+}